@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gomonome/monome"
+	"github.com/metakeule/config"
+)
+
+var (
+	cfg         = config.MustNew("monome-virtual", "0.0.1", "monome-virtual creates a pseudo terminal that acts like a monome 128 (mext protocol) and shows the grid in the terminal, where the keys can be clicked with the mouse")
+	argLink     = cfg.NewString("link", "path of a symlink to the pseudo terminal, e.g. /tmp/monome")
+	argHeadless = cfg.NewBool("headless", "print the lights as text instead of showing the grid, e.g. on CI machines", config.Default(false))
+)
+
+func main() {
+	err := run()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	err := cfg.Run()
+
+	if err != nil {
+		return err
+	}
+
+	master, slave, path, err := openPTY()
+	if err != nil {
+		return err
+	}
+	defer master.Close()
+	// as long as the slave is open, reading from the master does not fail when the clients disconnect
+	defer slave.Close()
+
+	if link := argLink.Get(); link != "" {
+		os.Remove(link)
+		if err := os.Symlink(path, link); err != nil {
+			return err
+		}
+		defer os.Remove(link)
+		path = link
+	}
+
+	grid := monome.Emulate128()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go fromClient(master, grid)
+	go toClient(ctx, master, grid)
+
+	if argHeadless.Get() {
+		fmt.Fprintf(os.Stdout, "monome 128 at %s\n", path)
+		printLights(ctx, grid)
+		return nil
+	}
+	return showGrid(ctx, stop, grid, path)
+}
+
+// fromClient passes the messages of the client to the grid
+func fromClient(master *os.File, grid *monome.Emulator) {
+	buf := make([]byte, 256)
+	for {
+		n, err := master.Read(buf)
+		if err != nil {
+			return
+		}
+		grid.Write(buf[:n])
+	}
+}
+
+// toClient passes the key reports of the grid to the client, without the status bytes of the FTDI chip
+func toClient(ctx context.Context, master *os.File, grid *monome.Emulator) {
+	buf := make([]byte, grid.MaxPacketSize())
+	for ctx.Err() == nil {
+		n, err := grid.Read(buf)
+		if err != nil {
+			return
+		}
+		if n > 2 {
+			master.Write(buf[2:n])
+		}
+	}
+}
+
+// snapshot returns the lights as text, like monome.Virtual.Snapshot
+func snapshot(grid *monome.Emulator) string {
+	var bf bytes.Buffer
+	for x := uint8(0); x < grid.Rows(); x++ {
+		for y := uint8(0); y < grid.Cols(); y++ {
+			if b := grid.Light(x, y); b == 0 {
+				bf.WriteByte('.')
+			} else {
+				fmt.Fprintf(&bf, "%x", b)
+			}
+		}
+		bf.WriteByte('\n')
+	}
+	return bf.String()
+}
+
+// printLights prints the lights, whenever they have changed
+func printLights(ctx context.Context, grid *monome.Emulator) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if s := snapshot(grid); s != last {
+			fmt.Fprintf(os.Stdout, "%s\n%s", time.Now().Format("15:04:05.000"), s)
+			last = s
+		}
+	}
+}
+
+const (
+	gridTop  = 3 // line of the first row
+	gridLeft = 2 // column of the first key
+	keyWidth = 3 // columns per key, including the gap
+)
+
+// mouseEvent is a mouse report in the SGR format: ESC [ < button ; column ; line M (press) or m (release)
+var mouseEvent = regexp.MustCompile(`\x1b\[<(\d+);(\d+);(\d+)([Mm])`)
+
+// showGrid shows the grid in the terminal and presses the keys that are clicked, until ctx is done or q is pressed
+func showGrid(ctx context.Context, stop func(), grid *monome.Emulator, path string) error {
+	restore, err := rawTerminal(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("can't show the grid (use -headless): %w", err)
+	}
+	defer restore()
+
+	// alternate screen, hidden cursor, mouse reports in SGR format
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l\x1b[?1000h\x1b[?1006h")
+	defer fmt.Fprint(os.Stdout, "\x1b[?1006l\x1b[?1000l\x1b[?25h\x1b[?1049l")
+
+	go readInput(grid, stop)
+
+	ticker := time.NewTicker(30 * time.Millisecond)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		if s := snapshot(grid); s != last {
+			draw(grid, path)
+			last = s
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func draw(grid *monome.Emulator, path string) {
+	var bf bytes.Buffer
+	fmt.Fprintf(&bf, "\x1b[Hmonome 128 at %s (q to quit)\x1b[K", path)
+	for x := uint8(0); x < grid.Rows(); x++ {
+		fmt.Fprintf(&bf, "\x1b[%d;%dH", gridTop+int(x), gridLeft)
+		for y := uint8(0); y < grid.Cols(); y++ {
+			// grays of the 256 colors, from dark (off) to white (15)
+			gray := 235 + int(grid.Light(x, y))*20/15
+			fmt.Fprintf(&bf, "\x1b[48;5;%dm  \x1b[0m ", gray)
+		}
+	}
+	os.Stdout.Write(bf.Bytes())
+}
+
+// readInput presses and releases the clicked keys. It calls stop for q and ctrl+c.
+func readInput(grid *monome.Emulator, stop func()) {
+	buf := make([]byte, 256)
+	held := false
+	var heldX, heldY uint8
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			stop()
+			return
+		}
+		in := buf[:n]
+		if !bytes.ContainsRune(in, 0x1b) && (bytes.ContainsRune(in, 'q') || bytes.ContainsRune(in, 0x03)) {
+			stop()
+			return
+		}
+		for _, m := range mouseEvent.FindAllSubmatch(in, -1) {
+			button, _ := strconv.Atoi(string(m[1]))
+			col, _ := strconv.Atoi(string(m[2]))
+			line, _ := strconv.Atoi(string(m[3]))
+			if button != 0 {
+				// only the left button
+				continue
+			}
+			if string(m[4]) == "m" {
+				// the release belongs to the key that has been pressed, wherever the mouse is
+				if held {
+					grid.Release(heldX, heldY)
+					held = false
+				}
+				continue
+			}
+			x, y := line-gridTop, (col-gridLeft)/keyWidth
+			if x < 0 || x >= int(grid.Rows()) || col < gridLeft || y >= int(grid.Cols()) || (col-gridLeft)%keyWidth == keyWidth-1 {
+				continue
+			}
+			if grid.Press(uint8(x), uint8(y)) == nil {
+				held, heldX, heldY = true, uint8(x), uint8(y)
+			}
+		}
+	}
+}
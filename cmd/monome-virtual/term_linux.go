@@ -0,0 +1,79 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/gomonome/monome/internal/term"
+)
+
+// openPTY creates a pseudo terminal in raw mode and returns its master, its slave and the path of the slave
+func openPTY() (master, slave *os.File, path string, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var n uint32
+	err = control(master, func(fd uintptr) error {
+		var unlock int32
+		if err := term.Ioctl(fd, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+			return err
+		}
+		if err := term.Ioctl(fd, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+			return err
+		}
+		// the termios of the master are the ones of the slave
+		_, err := term.MakeRaw(fd, 0)
+		return err
+	})
+	if err != nil {
+		master.Close()
+		return nil, nil, "", err
+	}
+
+	path = fmt.Sprintf("/dev/pts/%d", n)
+	slave, err = os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, "", err
+	}
+	return master, slave, path, nil
+}
+
+// rawTerminal switches the terminal to raw mode and returns a function to restore the previous mode
+func rawTerminal(f *os.File) (restore func(), err error) {
+	var old syscall.Termios
+	err = control(f, func(fd uintptr) (err error) {
+		old, err = term.MakeRaw(fd, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		control(f, func(fd uintptr) error {
+			return term.Restore(fd, old)
+		})
+	}, nil
+}
+
+// control calls fn with the file descriptor of f, without switching f to blocking mode
+func control(f *os.File, fn func(fd uintptr) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	err = rc.Control(func(fd uintptr) {
+		fnErr = fn(fd)
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
@@ -0,0 +1,19 @@
+//go:build !linux || !(386 || amd64 || arm || arm64 || riscv64)
+
+package main
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+var errUnsupported = errors.New("pseudo terminals are not supported on " + runtime.GOOS + "/" + runtime.GOARCH)
+
+func openPTY() (master, slave *os.File, path string, err error) {
+	return nil, nil, "", errUnsupported
+}
+
+func rawTerminal(f *os.File) (restore func(), err error) {
+	return nil, errUnsupported
+}
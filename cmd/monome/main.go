@@ -1,32 +1,43 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
-	"runtime"
+	"sync"
 	"time"
 
 	"github.com/gomonome/monome"
+	"github.com/gomonome/monome/record"
 	"github.com/goosc/osc"
 	"github.com/metakeule/config"
 )
 
 var (
 	monomeConnection monome.Connection
+	connectionMx     sync.Mutex
 	prefix           string
 	listener         osc.Listener
 	oscWriter        io.WriteCloser
-	sigchan          = make(chan os.Signal, 10)
-	stopScanning     = make(chan bool)
-	cleanup          = make(chan bool, 4)
-	newConnection    = make(chan monome.Connection, 4)
 
 	cfg           = config.MustNew("monome", "0.0.7", "monome creates and OSC connection to the first available monome")
 	argInaddress  = cfg.NewString("in", "address the monome is receiving from", config.Default("127.0.0.1:8082"))
 	argOutaddress = cfg.NewString("out", "address the monome is sending to", config.Default("127.0.0.1:8002"))
 	argPrefix     = cfg.NewString("prefix", "prefix for messages to address the monome device")
+	argRotation   = cfg.NewInt32("rotation", "clockwise rotation of the monome device in degrees (0, 90, 180 or 270)", config.Default(int32(0)))
+
+	cmdRecord     = cfg.MustCommand("record", "record the keys and lights of the first available monome to a session file, while connecting it to OSC")
+	argRecordFile = cmdRecord.NewString("file", "file the session is written to", config.Default("session.jsonl"))
+
+	cmdPlay     = cfg.MustCommand("play", "play the keys of a session file as OSC messages, without a monome")
+	argPlayFile = cmdPlay.NewString("file", "file the session is read from", config.Default("session.jsonl"))
+	argSpeed    = cmdPlay.NewFloat32("speed", "factor of the playing speed, 2 plays twice as fast as recorded", config.Default(float32(1)))
+
+	// recording is the file the session is recorded to, if any
+	recording *os.File
+	recorder  *record.Recorder
 )
 
 func main() {
@@ -48,6 +59,18 @@ func run() error {
 
 	prefix = argPrefix.Get()
 
+	switch cfg.ActiveCommand() {
+	case cmdPlay:
+		return play()
+	case cmdRecord:
+		recording, err = os.Create(argRecordFile.Get())
+		if err != nil {
+			return err
+		}
+		defer recording.Close()
+		fmt.Fprintf(os.Stdout, "recording to %s\n", argRecordFile.Get())
+	}
+
 	listener, err = osc.UDPListener(argInaddress.Get())
 	if err != nil {
 		return err
@@ -63,28 +86,138 @@ func run() error {
 
 	fmt.Fprintf(os.Stdout, "writing to UDP %s\n", argOutaddress.Get())
 
-	go manageConnections()
-	go scanForConnections()
+	err = listener.StartListening(oscHandler{})
+	if err != nil {
+		return err
+	}
+
+	manager := monome.NewManager(time.Second,
+		// the OSC messages use x for the column and y for the row
+		monome.Coordinates(monome.ColRow),
+		monome.Rotate(monome.RotationDegrees(int(argRotation.Get()))),
+	)
+	manager.SetEventHandler(monome.EventHandlerFunc(handleDevice))
 
 	// listen for ctrl+c
-	go signal.Notify(sigchan, os.Interrupt)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	// interrupt has happend
-	<-sigchan
+	// runs until the interrupt has happend
+	err = manager.Run(ctx)
+	if err != context.Canceled {
+		return err
+	}
 
 	fmt.Fprint(os.Stdout, "\ninterrupted...")
-	//	cleanup <- true
-	if monomeConnection != nil {
-		fmt.Printf("closing %s", monomeConnection)
-		listener.StopListening()
-		monomeConnection.Close()
-		oscWriter.Close()
-	}
+	listener.StopListening()
+	oscWriter.Close()
+	stopRecording()
 	fmt.Fprint(os.Stdout, "\ndone\n")
-	os.Exit(0)
-
 	return nil
+}
+
+// play sends the keys of the session as OSC messages, until the session is over or interrupted
+func play() error {
+	f, err := os.Open(argPlayFile.Get())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
+	session, err := record.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	oscWriter, err = osc.UDPWriter(argOutaddress.Get())
+	if err != nil {
+		return err
+	}
+	defer oscWriter.Close()
+
+	fmt.Fprintf(os.Stdout, "playing %s (recorded %s) to UDP %s\n", argPlayFile.Get(), session.Started.Format(time.RFC1123), argOutaddress.Get())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	player := record.Player{Speed: float64(argSpeed.Get()), Convention: monome.ColRow}
+	err = player.Play(ctx, session, nil, monome.HandlerFunc(sendKey))
+	if err == context.Canceled {
+		fmt.Fprint(os.Stdout, "\ninterrupted\n")
+		return nil
+	}
+	return err
+}
+
+// startRecording wraps the connection with a recorder, if a session should be recorded.
+// Only the first connection is recorded.
+func startRecording(conn monome.Connection) monome.Connection {
+	connectionMx.Lock()
+	defer connectionMx.Unlock()
+	if recording == nil || recorder != nil {
+		return conn
+	}
+	rec, err := record.New(conn, recording)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "ERROR: can't record: %v\n", err)
+		return conn
+	}
+	recorder = rec
+	return rec
+}
+
+// stopRecording stops the recording, if there is one
+func stopRecording() {
+	connectionMx.Lock()
+	defer connectionMx.Unlock()
+	if recorder == nil {
+		return
+	}
+	if err := recorder.Stop(); err != nil {
+		fmt.Fprintf(os.Stdout, "ERROR: recording failed: %v\n", err)
+	}
+	// don't start a new recording for the next device
+	recording = nil
+	fmt.Fprintf(os.Stdout, "recording stopped\n")
+}
+
+// handleDevice uses the first device that is plugged in, until it is removed
+func handleDevice(conn monome.Connection, ev monome.Event) {
+	switch e := ev.(type) {
+	case monome.DeviceAddedEvent:
+		if currentConnection() != nil {
+			return
+		}
+		fmt.Fprintf(os.Stdout, "found: %s\n", conn.String())
+		conn = startRecording(conn)
+		setConnection(conn)
+		go initConnection(conn)
+	case monome.DeviceRemovedEvent:
+		current := currentConnection()
+		if rec, ok := current.(*record.Recorder); ok {
+			current = rec.Connection
+		}
+		if current != conn {
+			return
+		}
+		fmt.Fprintf(os.Stdout, "closing %s\n", conn.String())
+		stopRecording()
+		setConnection(nil)
+	case monome.ErrorEvent:
+		fmt.Fprintf(os.Stdout, "ERROR: %v\n", e.Err)
+	}
+}
+
+func currentConnection() monome.Connection {
+	connectionMx.Lock()
+	defer connectionMx.Unlock()
+	return monomeConnection
+}
+
+func setConnection(conn monome.Connection) {
+	connectionMx.Lock()
+	monomeConnection = conn
+	connectionMx.Unlock()
 }
 
 type message struct {
@@ -119,9 +252,9 @@ func getMessage(values ...interface{}) message {
 
 		switch i {
 		case 0:
-			what = &msg.y
-		case 1:
 			what = &msg.x
+		case 1:
+			what = &msg.y
 		case 2:
 			what = &msg.brightness
 		}
@@ -136,7 +269,7 @@ func getMessage(values ...interface{}) message {
 			}
 			*what = uint8(val)
 		default:
-			fmt.Fprintf(os.Stderr, "unsupported type: %T (%v)", v)
+			fmt.Fprintf(os.Stderr, "unsupported type: %T (%v)\n", v, v)
 
 		}
 	}
@@ -152,8 +285,8 @@ func (o oscHandler) Handle(path osc.Path, values ...interface{}) {
 	pref := currentPrefix()
 	switch path.String() {
 	case pref + "/clear":
-		if monomeConnection != nil {
-			monome.SwitchAll(monomeConnection, false)
+		if conn := currentConnection(); conn != nil {
+			monome.SwitchAll(conn, false)
 		}
 	case pref + "/grid/led/intensity":
 		// ignore
@@ -161,6 +294,14 @@ func (o oscHandler) Handle(path osc.Path, values ...interface{}) {
 		// ignore
 	case "/sys/prefix":
 		prefix = values[1].(string)
+	case "/sys/rotation":
+		//   /sys/rotation d
+		//   rotate the monome by d degrees, where d is 0, 90, 180 or 270
+		if len(values) > 0 {
+			if degrees, ok := values[0].(int32); ok {
+				setRotation(monome.RotationDegrees(int(degrees)))
+			}
+		}
 	case pref + "/grid/led/set", prefix + "/led":
 		var msg = getMessage(values...)
 		if msg.brightness > 0 {
@@ -176,72 +317,38 @@ func (o oscHandler) Handle(path osc.Path, values ...interface{}) {
 
 }
 
-func initConnection(conn monome.Connection) error {
+func initConnection(conn monome.Connection) {
 	monome.Greeter(conn)
-	conn.SetHandler(monome.HandlerFunc(func(d monome.Connection, x, y uint8, down bool) {
-		//   /grid/key x y s
-		//   key state change at (x,y) to s (0 or 1, 1 = key down, 0 = key up).
+	conn.SetHandler(monome.HandlerFunc(sendKey))
+	// the manager removes the connection on errors
+	conn.StartListening(nil)
+}
 
-		var downVal int32
-		if down {
-			downVal = 1
-		}
-		osc.III(prefix+"/grid/key").WriteTo(oscWriter, int32(y), int32(x), downVal)
-	}))
-	conn.StartListening(func(err error) {
-		cleanup <- true
-	})
+func sendKey(d monome.Connection, x, y uint8, down bool) {
+	//   /grid/key x y s
+	//   key state change at (x,y) to s (0 or 1, 1 = key down, 0 = key up).
 
-	return listener.StartListening(oscHandler{})
+	var downVal int32
+	if down {
+		downVal = 1
+	}
+	osc.III(currentPrefix()+"/grid/key").WriteTo(oscWriter, int32(x), int32(y), downVal)
 }
 
-func sendMessage(msg message) {
-	monomeConnection.Set(msg.x, msg.y, msg.brightness)
-}
-
-func manageConnections() {
-	for {
-		select {
-		case conn := <-newConnection:
-			fmt.Fprintf(os.Stdout, "found: %s\n", conn.String())
-			monomeConnection = conn
-			stopScanning <- true
-			err := initConnection(conn)
-			if err != nil {
-				fmt.Printf("ERROR: %v\n", err)
-				monomeConnection.Close()
-			}
-			return
-		default:
-			runtime.Gosched()
-		}
+func setRotation(r monome.Rotation) {
+	conn := currentConnection()
+	if conn == nil {
+		return
 	}
+	if o, ok := conn.(monome.Orienter); ok {
+		o.SetRotation(r)
+		return
+	}
+	fmt.Fprintf(os.Stdout, "rotation is not supported by %s\n", conn.String())
 }
 
-func scanForConnections() {
-	t := time.NewTicker(time.Second)
-	for {
-		select {
-		case <-stopScanning:
-			fmt.Println("stop scanning")
-			t.Stop()
-			return
-		case <-t.C:
-			conns, err := monome.Connections()
-
-			if err != nil {
-				fmt.Fprintf(os.Stdout, "ERROR: %v", err)
-				sigchan <- os.Interrupt
-				t.Stop()
-				return
-			}
-
-			for _, conn := range conns {
-				newConnection <- conn
-			}
-		default:
-			runtime.Gosched()
-
-		}
+func sendMessage(msg message) {
+	if conn := currentConnection(); conn != nil {
+		conn.Set(msg.x, msg.y, msg.brightness)
 	}
 }
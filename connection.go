@@ -1,8 +1,10 @@
 package monome
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"io"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -13,7 +15,9 @@ import (
 type Handler interface {
 
 	// Handle is the callback that is called if a button is pressed (down=true)
-	// or released (down=false)
+	// or released (down=false).
+	// The meaning of x and y depends on the Convention of the connection,
+	// by default x is the row and y is the column (see Coordinates and PointHandlerFunc).
 	Handle(d Connection, x, y uint8, down bool)
 }
 
@@ -24,7 +28,12 @@ func (h HandlerFunc) Handle(d Connection, x, y uint8, down bool) {
 	h(d, x, y, down)
 }
 
-// Connection is a connection to a monome device
+// Connection is a connection to a monome device.
+//
+// All methods of a Connection are safe for concurrent use by multiple goroutines.
+// The handlers are called from the goroutines that listen to the devices, so for connections
+// that are made of several devices, they might be called concurrently.
+// Handlers may call any method of the Connection, including StopListening and Close.
 type Connection interface {
 	// Close closes the connection to the monome
 	Close() error
@@ -32,30 +41,95 @@ type Connection interface {
 	// IsClosed returns wether the connection is closed
 	IsClosed() bool
 
-	// SetHandler set the active handler for the device
+	// SetHandler set the active handler for the key events of the device.
+	// It is a shortcut for SetEventHandler(KeyHandler(h)).
 	SetHandler(Handler)
 
-	// StartListering starts listening for button events. For errors the given errHandler is called
+	// SetEventHandler sets the active handler for all events of the device
+	SetEventHandler(EventHandler)
+
+	// Subscribe adds a handler for the events, in addition to the handler that is set
+	// by SetHandler or SetEventHandler. The subscriptions get the events in the order
+	// they have been subscribed, before the handler. The options allow to filter the events.
+	// If the handler is an Interceptor, it can stop the propagation of an event.
+	// The returned function removes the subscription.
+	Subscribe(h EventHandler, options ...SubscribeOption) (unsubscribe func())
+
+	// StartListering starts listening for button events in the background.
+	// For errors the given errHandler is called.
 	StartListening(errHandler func(error))
 
+	// Listen listens for button events until the given context is done,
+	// StopListening is called, the connection is closed or an error happens.
+	// It returns the error of the context, the error that stopped the listening
+	// or nil if the listening was stopped or the connection was closed.
+	Listen(ctx context.Context) error
+
 	// StopListening stops listening for button events
 	StopListening()
 
+	// Done returns a channel that is closed when the connection is closed,
+	// either by calling Close or because of an error
+	Done() <-chan struct{}
+
+	// Err returns nil, as long as Done is not closed. Afterwards it returns
+	// the error that ended the connection or an error wrapping ErrClosed,
+	// if it was closed by calling Close.
+	Err() error
+
+	// Sync waits until the lights that have been queued (see WriteQueue) have been sent to the device.
+	// It returns the last error of these writes since the previous call of Sync.
+	Sync() error
+
+	// IsDown returns wether the key at x,y is held down
+	IsDown(x, y uint8) bool
+
+	// PressedAt returns when the key at x,y has been pressed and false, if it is not held down
+	PressedAt(x, y uint8) (time.Time, bool)
+
+	// HeldKeys returns the keys that are held down, in the order of their presses.
+	// The held keys are forgotten, when the listening stops or the device is removed.
+	HeldKeys() []HeldKey
+
+	// Events returns a channel that receives the events of the connection,
+	// starting with a DeviceAddedEvent and ending with a DeviceRemovedEvent.
+	// The channel is created on the first call and closed when the connection is closed.
+	// The events are delivered in addition to the calls of the handler.
+	Events() <-chan Event
+
 	Device
 }
 
 type connection struct {
 	Device
-	dev               io.Closer //    *usb.Device
-	h                 Handler
-	usbReader         io.Reader //  usb.Endpoint
-	usbWriter         io.Writer // usb.Endpoint
+	dispatcher
+	dev       io.Closer //    *usb.Device or Transport
+	serial    string
+	usbReader io.Reader //  usb.Endpoint or Transport
+	usbWriter io.Writer // usb.Endpoint or Transport
+	usbCtx    io.Closer // *usb.Context, if the connection has its own
+	lifecycle
 	closed            bool
+	devClosed         bool
 	mx                sync.RWMutex
-	listeningStopped  chan bool
+	io                sync.RWMutex
+	stop              chan struct{}
+	stopped           chan struct{}
+	stopping          bool
+	handlers          int        // number of running calls of the handlers by the listening
+	self              Connection // passed to the handlers instead of the connection, if not nil
 	maxpacketSizeRead uint16
 	pollInterval      time.Duration
-	doneChan          chan bool
+	readTimeout       time.Duration
+	latency           time.Duration
+	idleReads         int // number of reads in a row without data, only used by the listening
+	handshakeDelay    time.Duration
+	reconnect         reconnection
+	reconnecting      chan struct{}
+	writes            *writeQueue
+	orientation       orientation
+	convention        Convention
+	logger            *slog.Logger
 }
 
 type monomeConnection interface {
@@ -63,146 +137,265 @@ type monomeConnection interface {
 	Handler
 	Connection
 	maxPacketSizeRead() uint16
+	conn() Connection
 }
 
 func (m *connection) maxPacketSizeRead() uint16 {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
 	return m.maxpacketSizeRead
 }
 
 func (m *connection) Read(b []byte) (int, error) {
-	var closed bool
+	// the device must not be closed while reading from it
+	m.io.RLock()
 	m.mx.RLock()
-	closed = m.closed
+	closed, reconnecting, r := m.closed, m.reconnecting != nil, m.usbReader
 	m.mx.RUnlock()
 	if closed {
+		m.io.RUnlock()
 		return 0, ConnectionClosedError(m.String())
 	}
+	if reconnecting {
+		m.io.RUnlock()
+		return 0, ErrDisconnected
+	}
 
-	i, err := m.usbReader.Read(b)
-	if err != nil {
-		fmt.Printf("stopping read/write to device %s, because of reading error: %v\n", m.String(), err)
-		m.mx.Lock()
-		m.closed = true
-		m.mx.Unlock()
-		//m.doneChan <- true
-		//close(m.doneChan)
+	start := time.Now()
+	i, err := r.Read(b)
+	m.io.RUnlock()
+	if err == nil && i <= len(ftdiStatus) {
+		m.idle(time.Since(start))
+	} else {
+		m.idleReads = 0
+	}
+	err = wrapUSBError(err)
+	if err != nil && !errors.Is(err, ErrTimeout) {
+		// reads are done by the listening, so a ReconnectingEvent is passed to its handlers
+		m.callHandlers(func() {
+			m.fail(err, "reading")
+		})
 	}
 	return i, err
 }
 
 func (m *connection) Write(b []byte) (int, error) {
-	var closed bool
+	// the device must not be closed while writing to it
+	m.io.RLock()
 	m.mx.RLock()
-	closed = m.closed
+	closed, reconnecting, w := m.closed, m.reconnecting != nil, m.usbWriter
 	m.mx.RUnlock()
 	if closed {
+		m.io.RUnlock()
 		return 0, ConnectionClosedError(m.String())
 	}
+	if reconnecting {
+		m.io.RUnlock()
+		return 0, ErrDisconnected
+	}
 
-	i, err := m.usbWriter.Write(b)
+	i, err := w.Write(b)
+	m.io.RUnlock()
+	err = wrapUSBError(err)
 	if err != nil {
-		fmt.Printf("stopping read/write to device %s, because of writing error: %v\n", m.String(), err)
-		m.mx.Lock()
-		m.closed = true
-		m.mx.Unlock()
-		//m.doneChan <- true
-		//close(m.doneChan)
+		m.fail(err, "writing")
 	}
 	return i, err
 }
 
-func (m *connection) StartListening(errHandler func(error)) {
-	go m.poll(errHandler, m)
+// fail handles an error while reading or writing. The connection is closed, unless
+// it can reconnect (see AutoReconnect).
+func (m *connection) fail(err error, task string) {
+	if m.startReconnect(err) {
+		m.logger.Warn("reconnecting to device, because of "+task+" error", "device", m.String(), "error", err)
+		return
+	}
+	m.logger.Error("stopping read/write to device, because of "+task+" error", "device", m.String(), "error", err)
+	m.markClosed(err)
+}
+
+// markClosed marks the connection as closed, because of the given error (nil if it was closed on purpose)
+func (m *connection) markClosed(err error) {
+	m.mx.Lock()
+	m.closed = true
+	m.mx.Unlock()
+	if m.writes != nil {
+		m.writes.close()
+	}
+	if err == nil {
+		m.finish(ConnectionClosedError(m.String()))
+	} else {
+		m.finish(err)
+	}
+	m.closeEvents(m.conn(), err)
 }
 
-func (m *connection) poll(errHandler func(error), d Connection) {
-	/*
-		if m.ticker != nil {
-			m.ticker.Stop()
+func (m *connection) Events() <-chan Event {
+	return m.eventsOf(m.conn())
+}
+
+// startSession registers a new listening session
+func (m *connection) startSession() (stop, stopped chan struct{}, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.closed {
+		return nil, nil, ConnectionClosedError(m.String())
+	}
+	if m.stopped != nil {
+		return nil, nil, ErrListening
+	}
+	m.stop = make(chan struct{})
+	m.stopped = make(chan struct{})
+	m.stopping = false
+	m.events.setStop(m.stop)
+	return m.stop, m.stopped, nil
+}
+
+func (m *connection) StartListening(errHandler func(error)) {
+	stop, stopped, err := m.startSession()
+	if err != nil {
+		if errHandler != nil && err != ErrListening {
+			errHandler(err)
+		}
+		return
+	}
+	go func() {
+		err := m.listen(context.Background(), stop, stopped)
+		if err != nil && errHandler != nil {
+			errHandler(err)
 		}
-		m.ticker = time.NewTicker(m.pollInterval)
-	*/
-	ticker := time.NewTicker(m.pollInterval)
-	tickChan := ticker.C
-
-	if errHandler == nil {
-		//go func() {
-		for {
+	}()
+}
+
+func (m *connection) Listen(ctx context.Context) error {
+	stop, stopped, err := m.startSession()
+	if err != nil {
+		return err
+	}
+	return m.listen(ctx, stop, stopped)
+}
+
+// listen reads from the device until ctx is done, the session is stopped or an error happens
+func (m *connection) listen(ctx context.Context, stop, stopped chan struct{}) error {
+	defer func() {
+		m.mx.Lock()
+		m.stop, m.stopped = nil, nil
+		m.mx.Unlock()
+		// the releases of the held keys are not noticed anymore
+		m.resetKeys()
+		m.callHandlers(func() {
+			m.dispatch(m.conn(), ListeningStoppedEvent{EventHeader: header(m)})
+		})
+		close(stopped)
+	}()
+
+	// without a poll interval, the reads block until there is data or the read timeout
+	// is reached, so the next read can start right away
+	var tick <-chan time.Time
+	if m.pollInterval > 0 {
+		ticker := time.NewTicker(m.pollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if tick != nil {
 			select {
-			case <-tickChan:
-				var closed bool
-				m.mx.RLock()
-				closed = m.closed
-				m.mx.RUnlock()
-				if closed {
-					return
-				}
-				err := d.ReadMessage()
-				if err != nil {
-					fmt.Printf("stop listening, because could not read from device %s: %v\n", m.String(), err)
-					ticker.Stop()
-					m.mx.Lock()
-					m.closed = true
-					m.mx.Unlock()
-					//close(m.doneChan)
-					return
-				}
-			case <-m.doneChan:
-				//				fmt.Println("done channel called")
-				ticker.Stop()
-				m.listeningStopped <- true
-				return
+			case <-tick:
+			case <-ctx.Done():
+			case <-stop:
+			case <-m.Done():
 			}
 		}
-		//}()
-	} else {
-		//go func() {
-		for {
+		if end, err := m.ended(ctx, stop); end {
+			return err
+		}
+
+		err := m.Device.ReadMessage()
+		if err == nil || errors.Is(err, ErrTimeout) {
+			continue
+		}
+		if wait := m.reconnected(); wait != nil {
+			// the device is lost, wait until it is back
 			select {
-			case <-tickChan:
-				var closed bool
-				m.mx.RLock()
-				closed = m.closed
-				m.mx.RUnlock()
-				if closed {
-					errHandler(ConnectionClosedError(m.String()))
-					return
-				}
-				if err := d.ReadMessage(); err != nil {
-					errHandler(err)
-					fmt.Printf("stop listening, because could not read from device %s: %v\n", m.String(), err)
-					ticker.Stop()
-					m.mx.Lock()
-					m.closed = true
-					m.mx.Unlock()
-					//close(m.doneChan)
-					return
-				}
-			case <-m.doneChan:
-				//				fmt.Println("done channel called")
-				ticker.Stop()
-				m.listeningStopped <- true
-				return
+			case <-wait:
+			case <-stop:
+			case <-ctx.Done():
+			case <-m.Done():
 			}
+			continue
+		}
+		select {
+		case <-stop:
+			// the connection was closed while reading
+			return nil
+		default:
 		}
-		//}()
+		m.logger.Error("stop listening, because could not read from device", "device", m.String(), "error", err)
+		m.callHandlers(func() {
+			m.dispatch(m.conn(), ErrorEvent{EventHeader: header(m), Err: err})
+			m.markClosed(err)
+		})
+		return err
 	}
 }
 
+// ended returns true and the result of the listening, if the listening should end
+func (m *connection) ended(ctx context.Context, stop chan struct{}) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return true, ctx.Err()
+	case <-stop:
+		return true, nil
+	case <-m.Done():
+		if errors.Is(m.Err(), ErrClosed) {
+			return true, nil
+		}
+		return true, m.Err()
+	default:
+		return false, nil
+	}
+}
+
+// StopListening stops listening and waits until the listening has stopped.
+// If it is called while a handler is running, e.g. from within the handler, it does not
+// wait, since the listening can only stop after the handler returned.
 func (m *connection) StopListening() {
-	//	fmt.Println("stop listening called")
-	if m.IsClosed() {
+	m.mx.Lock()
+	stop, stopped, handlers := m.stop, m.stopped, m.handlers
+	if stop != nil {
+		close(stop)
+		m.stop = nil
+		m.stopping = true
+	}
+	m.mx.Unlock()
+
+	if stopped == nil || handlers > 0 {
 		return
 	}
-	m.doneChan <- true
-	/*
+	<-stopped
+}
+
+// conn returns the Connection that is passed to the handlers, e.g. the Virtual that embeds the connection
+func (m *connection) conn() Connection {
+	if m.self != nil {
+		return m.self
+	}
+	return m
+}
+
+// callHandlers calls fn, which passes events of the listening to the handlers.
+// While it runs, StopListening does not wait, since it might be called by a handler.
+func (m *connection) callHandlers(fn func()) {
+	m.mx.Lock()
+	m.handlers++
+	m.mx.Unlock()
+	defer func() {
 		m.mx.Lock()
-		m.closed = true
-		//close(m.doneChan)
+		m.handlers--
 		m.mx.Unlock()
-	*/
-	<-m.listeningStopped
-	//	fmt.Println("listening has stopped")
+	}()
+	fn()
 }
 
 func (m *connection) Flash() {
@@ -234,14 +427,14 @@ func (m *connection) worm() {
 			mx.Lock()
 			wg.Add(1)
 			//m.On(x, uint8(y))
-			m.Set(x, uint8(y), 4+x)
+			SetPoint(m, Point{Row: x, Col: uint8(y)}, 4+x)
 			mx.Unlock()
 			//			println(x, y)
 
 			go func(_x, _y uint8) {
 				time.Sleep(time.Millisecond * (i*i*i*7 + 47))
 				mx.Lock()
-				m.Switch(_x, _y, false)
+				SwitchPoint(m, Point{Row: _x, Col: _y}, false)
 				wg.Done()
 				mx.Unlock()
 			}(x, uint8(y))
@@ -258,6 +451,11 @@ func (m *connection) worm() {
 	wg.Wait()
 }
 
+// ID returns the name and the serial number of the device
+func (m *connection) ID() DeviceID {
+	return DeviceID{Name: m.String(), Serial: m.serial}
+}
+
 func (m *connection) IsClosed() bool {
 	m.mx.RLock()
 	closed := m.closed
@@ -265,19 +463,40 @@ func (m *connection) IsClosed() bool {
 	return closed
 }
 
+// Close stops the listening, sends the queued lights and closes the device.
+// It may be called several times and from within a handler.
 func (m *connection) Close() (err error) {
-	if m.IsClosed() {
+	m.StopListening()
+	m.mx.Lock()
+	devClosed := m.devClosed
+	m.devClosed = true
+	m.mx.Unlock()
+	if devClosed {
 		return nil
 	}
 
-	m.StopListening()
+	// send the queued lights before closing
+	m.Sync()
+	m.markClosed(nil)
+
+	// wait for running reads and writes
+	m.io.Lock()
+	defer m.io.Unlock()
+
 	m.mx.Lock()
-	if !m.closed {
-		m.closed = true
-	}
+	dev, usbCtx := m.dev, m.usbCtx
+	m.dev, m.usbCtx = nil, nil
 	m.mx.Unlock()
 
-	err = m.dev.Close()
+	if usbCtx != nil {
+		defer usbCtx.Close()
+	}
+	if dev == nil {
+		// the device was lost while reconnecting
+		return nil
+	}
+
+	err = dev.Close()
 	if err == nil {
 		return
 	}
@@ -288,24 +507,72 @@ func (m *connection) Close() (err error) {
 	}
 }
 
-func (m *connection) SetHandler(h Handler) {
-	m.mx.Lock()
-	m.h = h
-	m.mx.Unlock()
+// defaultReadTimeout is the time a read waits for data. It limits how long
+// StopListening has to wait for a running read.
+var defaultReadTimeout = 100 * time.Millisecond
+
+// defaultLatency is the latency timer of the FTDI chip, the shortest one lets
+// the key presses through right away
+var defaultLatency = time.Millisecond
+
+// idleBackoff is the time between the reads of a device that answers right away
+// without data, like an idle FTDI chip after each latency. It is reached after
+// idleAfter reads in a row without data, so the first key press after a pause
+// waits no longer than with the polling of earlier versions.
+const (
+	idleBackoff = 4 * time.Millisecond
+	idleAfter   = 8
+)
+
+// idle slows the reads down, when the device had no data for a while
+func (m *connection) idle(took time.Duration) {
+	if m.idleReads < idleAfter {
+		m.idleReads++
+		return
+	}
+	if took < idleBackoff {
+		time.Sleep(idleBackoff - took)
+	}
+}
+
+// latencyMillis returns the latency in milliseconds as the FTDI chip takes it
+func latencyMillis(latency time.Duration) uint16 {
+	ms := latency.Round(time.Millisecond) / time.Millisecond
+	switch {
+	case ms < 1:
+		return 1
+	case ms > 255:
+		return 255
+	default:
+		return uint16(ms)
+	}
 }
 
-var defaultPollInterval = 4 * time.Millisecond
+// readBuffer returns the buffer of the given size, reusing buf
+func readBuffer(buf *[]byte, size uint16) []byte {
+	if len(*buf) != int(size) {
+		*buf = make([]byte, size)
+	}
+	return *buf
+}
 
 func (m *connection) Handle(d Connection, x, y uint8, down bool) {
-	if m.h != nil {
-		m.h.Handle(d, x, y, down)
+	m.mx.RLock()
+	stopping := m.stopping
+	m.mx.RUnlock()
+	if stopping {
+		// StopListening has been called while reading
 		return
 	}
-	action := "release"
-	if down {
-		action = "press"
+	x, y = m.fromDevice(x, y)
+	var handled bool
+	m.callHandlers(func() {
+		handled = m.handle(d, x, y, down)
+	})
+	if handled {
+		return
 	}
-	fmt.Printf("unhandled key %s on device %s: x: %d, y: %d\n", action, d.String(), x, y)
+	m.logger.Debug("unhandled key", "device", d.String(), "x", x, "y", y, "down", down)
 }
 
 // Connect returns a new Connection to the given usb.Device.
@@ -314,24 +581,45 @@ func (m *connection) Handle(d Connection, x, y uint8, down bool) {
 func Connect(dev *usb.Device, options ...Option) (d *connection, err error) {
 	//printDevice(dev)
 	var m = &connection{
-		dev: dev,
-		//pollInterval: 7 * time.Millisecond,
-		pollInterval: defaultPollInterval,
+		dev:            dev,
+		serial:         usbSerial(dev),
+		readTimeout:    defaultReadTimeout,
+		latency:        defaultLatency,
+		handshakeDelay: defaultHandshakeDelay,
+		logger:         newLogger(nil),
+		dispatcher:     newDispatcher(),
 	}
-	m.doneChan = make(chan bool)
-	m.listeningStopped = make(chan bool)
 
 	for _, opt := range options {
 		opt(m)
 	}
 
+	device, err := m.open(dev)
+	if err != nil {
+		return nil, err
+	}
+	m.Device = device
+	m.startWrites()
+	return m, nil
+}
+
+const (
+	ftdiRequestOut      = 0x40
+	ftdiSetLatencyTimer = 0x09
+)
+
+// open opens the endpoints of the given usb device, identifies the kind of monome
+// and returns the matching Device
+func (m *connection) open(dev *usb.Device) (Device, error) {
+	dev.ReadTimeout = m.readTimeout
+
 	cfg := dev.Descriptor.Configs[0]
 	iff := cfg.Interfaces[0]
 	setup := iff.Setups[0]
 
 	//	var t string = setup.Endpoints[0].Address
 
-	m.usbReader, err = dev.OpenEndpoint(cfg.Config, iff.Number, setup.Number, setup.Endpoints[0].Address)
+	usbReader, err := dev.OpenEndpoint(cfg.Config, iff.Number, setup.Number, setup.Endpoints[0].Address)
 
 	if err != nil {
 		var e ConnectError
@@ -342,14 +630,14 @@ func Connect(dev *usb.Device, options ...Option) (d *connection, err error) {
 		e.USBEndPoint.Interface = iff.Number
 		e.USBEndPoint.Setup = setup.Number
 		e.USBEndPoint.Info = setup.Endpoints[0]
-		e.WrappedError = err
+		e.WrappedError = wrapUSBError(err)
 		return nil, &e
 	}
-	m.maxpacketSizeRead = setup.Endpoints[0].MaxPacketSize
+	maxpacketSizeRead := setup.Endpoints[0].MaxPacketSize
 
 	//	m.maxPacketSizeRead = setup.Endpoints[0].MaxPacketSize
 
-	m.usbWriter, err = dev.OpenEndpoint(cfg.Config, iff.Number, setup.Number, setup.Endpoints[1].Address)
+	usbWriter, err := dev.OpenEndpoint(cfg.Config, iff.Number, setup.Number, setup.Endpoints[1].Address)
 	if err != nil {
 		var e ConnectError
 		e.USBDevice = dev
@@ -359,55 +647,32 @@ func Connect(dev *usb.Device, options ...Option) (d *connection, err error) {
 		e.USBEndPoint.Interface = iff.Number
 		e.USBEndPoint.Setup = setup.Number
 		e.USBEndPoint.Info = setup.Endpoints[1]
-		e.WrappedError = err
+		e.WrappedError = wrapUSBError(err)
 		return nil, &e
 	}
 
-	var errs Errors
-
-	_, err = m.usbWriter.Write([]byte{0x01, 0x00, 0x00})
-
+	device, err := m.identify(usbReader, usbWriter, maxpacketSizeRead)
 	if err != nil {
-		errs.Add(err)
-		errs.Task = "initial write to find out the kind of monome"
-		return nil, &errs
+		var e *UnknownMonomeError
+		if errors.As(err, &e) {
+			e.USBDevice = dev
+			e.USBReaderEndPoint = setup.Endpoints[0]
+			e.USBWriterEndPoint = setup.Endpoints[1]
+		}
+		return nil, err
 	}
-	time.Sleep(time.Second)
-	var b = make([]byte, int(m.maxpacketSizeRead))
-	_, err = m.usbReader.Read(b)
 
+	// the FTDI chip collects the data for up to the latency before sending it
+	_, err = dev.Control(ftdiRequestOut, ftdiSetLatencyTimer, latencyMillis(m.latency), uint16(iff.Number)+1, nil)
 	if err != nil {
-		errs.Add(err)
-		errs.Task = "initial read to find out the kind of monome"
-		return nil, &errs
+		m.logger.Warn("could not set the latency timer", "device", device.String(), "latency", m.latency, "error", wrapUSBError(err))
 	}
 
-	//	fmt.Printf("% X (%s) len: %v\n", b[:ln], string(b[:ln]), ln)
-
-	//	monome16x8 = "m1000293" -> 0xF4365 oder 1000293
-	//
-	//	monome8x8  = "m64-0348" -> 0x15C
-
-	if string(b[3:13]) == "monome 128" {
-		m.Device = &m128{m}
-		//		m.Flash()
-		return m, nil
-	}
-
-	if b[0] == 0x31 {
-		//		fmt.Println("monome64")
-		m.Device = &m64{m}
-		//		m.Flash()
-		return m, nil
-	}
-
-	var e UnknownMonomeError
-	e.Response = b
-	e.USBDevice = dev
-	e.USBReaderEndPoint = setup.Endpoints[0]
-	e.USBWriterEndPoint = setup.Endpoints[1]
-	return nil, &e
-
+	m.mx.Lock()
+	m.dev = dev
+	m.usbReader = usbReader
+	m.usbWriter = usbWriter
+	m.maxpacketSizeRead = maxpacketSizeRead
+	m.mx.Unlock()
+	return device, nil
 }
-
-// serial = udev_device_get_property_value(d, "ID_SERIAL_SHORT");
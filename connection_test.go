@@ -0,0 +1,208 @@
+package monome
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// hammer uses the connection from several goroutines at once, to be run with -race
+func hammer(t *testing.T, c Connection, press func(i int)) {
+	t.Helper()
+	var wg sync.WaitGroup
+	run := func(n int, fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	rows, cols := c.Rows(), c.Cols()
+	run(200, func(i int) {
+		c.Set(uint8(i)%rows, uint8(i/2)%cols, uint8(i%16))
+	})
+	run(200, func(i int) {
+		c.Switch(uint8(i/3)%rows, uint8(i)%cols, i%2 == 0)
+	})
+	run(50, func(i int) {
+		if i%2 == 0 {
+			c.SetHandler(HandlerFunc(func(d Connection, x, y uint8, down bool) {
+				d.Set(x, y, 15)
+			}))
+			return
+		}
+		c.SetEventHandler(EventHandlerFunc(func(d Connection, ev Event) {
+			if ke, ok := ev.(KeyEvent); ok && ke.X == 0 && ke.Y == 0 {
+				d.StopListening()
+			}
+		}))
+	})
+	run(50, func(i int) {
+		c.StartListening(nil)
+		time.Sleep(time.Millisecond)
+		c.StopListening()
+	})
+	run(200, func(i int) {
+		press(i)
+	})
+	run(100, func(i int) {
+		c.IsDown(uint8(i)%rows, 0)
+		c.HeldKeys()
+	})
+	wg.Wait()
+
+	closed := make(chan error, 2)
+	c.StartListening(nil)
+	go func() { closed <- c.Close() }()
+	go func() { closed <- c.Close() }()
+	for i := 0; i < 2; i++ {
+		if err := <-closed; err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	}
+	if !c.IsClosed() {
+		t.Errorf("IsClosed() = false after Close")
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Done() not closed after Close")
+	}
+}
+
+func TestConnectionConcurrentUse(t *testing.T) {
+	v := NewVirtual(8, 16, ReadTimeout(5*time.Millisecond))
+	hammer(t, v, func(i int) {
+		v.Press(uint8(i)%8, uint8(i)%16)
+		v.Release(uint8(i)%8, uint8(i)%16)
+	})
+}
+
+func TestConnectionConcurrentUseWithWriteQueue(t *testing.T) {
+	v := NewVirtual(8, 8, ReadTimeout(5*time.Millisecond), WriteQueue(8, 0), Rotate(Rotate90))
+	hammer(t, v, func(i int) {
+		v.Tap(uint8(i)%8, uint8(i/8)%8)
+	})
+}
+
+func TestRowConnectionConcurrentUse(t *testing.T) {
+	a, b := NewVirtual(8, 8, ReadTimeout(5*time.Millisecond)), NewVirtual(8, 8, ReadTimeout(5*time.Millisecond))
+	hammer(t, RowConnection("row", a, b), func(i int) {
+		member := a
+		if i%2 == 1 {
+			member = b
+		}
+		member.Tap(uint8(i)%8, uint8(i/2)%8)
+	})
+}
+
+func TestStopListeningWaits(t *testing.T) {
+	v := NewVirtual(8, 8, ReadTimeout(50*time.Millisecond))
+	defer v.Close()
+	v.StartListening(nil)
+	// let the listening start a read
+	time.Sleep(5 * time.Millisecond)
+
+	v.StopListening()
+	v.mx.RLock()
+	listening := v.stopped != nil
+	v.mx.RUnlock()
+	if listening {
+		t.Fatal("StopListening returned before the listening stopped")
+	}
+}
+
+func TestStopListeningWhileHandling(t *testing.T) {
+	v := NewVirtual(8, 8, ReadTimeout(5*time.Millisecond))
+	defer v.Close()
+	inHandler := make(chan struct{})
+	release := make(chan struct{})
+	var calls int
+	v.SetHandler(HandlerFunc(func(d Connection, x, y uint8, down bool) {
+		calls++
+		if calls == 1 {
+			close(inHandler)
+			<-release
+		}
+	}))
+	v.StartListening(nil)
+	v.Press(1, 1)
+	v.Press(2, 2)
+	<-inHandler
+
+	// the handler is running, so StopListening must not wait for it
+	stopped := make(chan struct{})
+	go func() {
+		v.StopListening()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("StopListening waited for the running handler")
+	}
+	close(release)
+
+	if err := v.Settle(50 * time.Millisecond); err == nil {
+		t.Error("the key pressed after StopListening has been handled")
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, expected 1", calls)
+	}
+}
+
+func TestStopListeningFromHandler(t *testing.T) {
+	v := NewVirtual(8, 8)
+	done := make(chan struct{})
+	v.SetHandler(HandlerFunc(func(d Connection, x, y uint8, down bool) {
+		d.StopListening()
+		d.Close()
+		close(done)
+	}))
+	v.StartListening(nil)
+	v.Press(0, 0)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StopListening and Close from within a handler blocked")
+	}
+}
+
+// idleChip answers every read after the latency with the status bytes alone, like an idle FTDI chip
+type idleChip struct {
+	latency time.Duration
+	mx      sync.Mutex
+	reads   int
+}
+
+func (c *idleChip) Read(b []byte) (int, error) {
+	time.Sleep(c.latency)
+	c.mx.Lock()
+	c.reads++
+	c.mx.Unlock()
+	return copy(b, ftdiStatus[:]), nil
+}
+func (c *idleChip) Write(b []byte) (int, error)  { return len(b), nil }
+func (c *idleChip) Close() error                 { return nil }
+func (c *idleChip) MaxPacketSize() uint16        { return 64 }
+func (c *idleChip) SetReadTimeout(time.Duration) {}
+
+func TestIdleReadsBackOff(t *testing.T) {
+	chip := &idleChip{latency: time.Millisecond}
+	m := &connection{dev: chip, usbReader: chip, usbWriter: chip, maxpacketSizeRead: 64,
+		readTimeout: defaultReadTimeout, logger: newLogger(nil), dispatcher: newDispatcher()}
+	m.Device = &m64{mn: m}
+	m.StartListening(nil)
+	time.Sleep(200 * time.Millisecond)
+	m.StopListening()
+
+	chip.mx.Lock()
+	defer chip.mx.Unlock()
+	// without backing off, there would be about 200 reads
+	if max := int(200*time.Millisecond/idleBackoff) + idleAfter + 5; chip.reads > max {
+		t.Errorf("%d reads of an idle device in 200ms, expected at most %d", chip.reads, max)
+	}
+}
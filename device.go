@@ -7,7 +7,9 @@ type Device interface {
 	// Cols returns the number of cols
 	Cols() uint8
 
-	// Set sets the button at position x,y to the given brightness
+	// Set sets the button at position x,y to the given brightness.
+	// The meaning of x and y depends on the Convention of the device,
+	// by default x is the row and y is the column (see SetPoint).
 	// If the connection has been closed, nothing is sent
 	// From the monome docs about brightness levels:
 	// [0, 3] - off
@@ -0,0 +1,387 @@
+package monome
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Emulator is a Transport that speaks the byte protocol of a monome 64 or a monome 128,
+// so that the drivers can be tested from ConnectTransport up without a device.
+// It answers the query for the kind of the device, keeps the lights that are sent to it
+// and sends the key reports of Press and Release in the packets of the FTDI chip.
+//
+// The positions are the positions on the device: x is the row and y the column.
+// Faults of the transmission can be injected with DropBytes, SplitReads and Fail.
+type Emulator struct {
+	protocol emulatorProtocol
+	rows     uint8
+	cols     uint8
+
+	mx          sync.Mutex
+	lights      []uint8
+	in          []byte // written, but not yet parsed
+	out         []byte // waiting to be read
+	readTimeout time.Duration
+	drop        int
+	split       int
+	err         error
+	closed      bool
+
+	// changed receives, when there is something to read
+	changed chan struct{}
+}
+
+var _ Transport = &Emulator{}
+
+// emulatorProtocol is the byte protocol of an emulated device
+type emulatorProtocol interface {
+	// parse parses the first message of the written bytes and returns its length
+	// or 0, if the message is not complete
+	parse(e *Emulator, in []byte) int
+
+	// key returns the key report
+	key(x, y uint8, down bool) []byte
+}
+
+// ftdiStatus are the status bytes, the FTDI chip sends at the start of each packet
+var ftdiStatus = [2]byte{0x31, 0x60}
+
+// Emulate64 returns an emulator of a monome 64 (8x8, series protocol)
+func Emulate64() *Emulator {
+	return newEmulator(series{}, 8, 8)
+}
+
+// Emulate128 returns an emulator of a monome 128 (8x16, mext protocol)
+func Emulate128() *Emulator {
+	return newEmulator(mext{}, 8, 16)
+}
+
+func newEmulator(p emulatorProtocol, rows, cols uint8) *Emulator {
+	return &Emulator{
+		protocol:    p,
+		rows:        rows,
+		cols:        cols,
+		lights:      make([]uint8, int(rows)*int(cols)),
+		readTimeout: defaultReadTimeout,
+		changed:     make(chan struct{}, 1),
+	}
+}
+
+func (e *Emulator) Rows() uint8 { return e.rows }
+func (e *Emulator) Cols() uint8 { return e.cols }
+
+// Press sends the pressing of the key at x,y
+func (e *Emulator) Press(x, y uint8) error {
+	return e.key(x, y, true)
+}
+
+// Release sends the releasing of the key at x,y
+func (e *Emulator) Release(x, y uint8) error {
+	return e.key(x, y, false)
+}
+
+func (e *Emulator) key(x, y uint8, down bool) error {
+	if x >= e.rows || y >= e.cols {
+		return OutOfRangeError{Device: "emulator", X: x, Y: y, Rows: e.rows, Cols: e.cols}
+	}
+	e.send(e.protocol.key(x, y, down))
+	return nil
+}
+
+// Light returns the brightness of the light at x,y
+func (e *Emulator) Light(x, y uint8) uint8 {
+	if x >= e.rows || y >= e.cols {
+		return 0
+	}
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	return e.lights[int(x)*int(e.cols)+int(y)]
+}
+
+// DropBytes makes the emulator lose the next n bytes of the key reports
+func (e *Emulator) DropBytes(n int) {
+	e.mx.Lock()
+	e.drop += n
+	e.mx.Unlock()
+}
+
+// SplitReads makes each read return at most n bytes of data (after the status bytes),
+// so that messages are split between reads. If n is 0, there is no limit.
+func (e *Emulator) SplitReads(n int) {
+	e.mx.Lock()
+	e.split = n
+	e.mx.Unlock()
+}
+
+// Fail makes the emulator behave like an unplugged device: the reads, writes
+// and the closing fail with the given error
+func (e *Emulator) Fail(err error) {
+	e.mx.Lock()
+	e.err = err
+	e.mx.Unlock()
+	e.notify()
+}
+
+// send queues the given bytes for reading
+func (e *Emulator) send(b []byte) {
+	e.mx.Lock()
+	if e.drop > 0 {
+		n := e.drop
+		if n > len(b) {
+			n = len(b)
+		}
+		e.drop -= n
+		b = b[n:]
+	}
+	e.out = append(e.out, b...)
+	e.mx.Unlock()
+	e.notify()
+}
+
+func (e *Emulator) notify() {
+	select {
+	case e.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (e *Emulator) setLight(x, y, brightness uint8) {
+	if x >= e.rows || y >= e.cols {
+		return
+	}
+	if brightness > 15 {
+		brightness = 15
+	}
+	e.lights[int(x)*int(e.cols)+int(y)] = brightness
+}
+
+// MaxPacketSize returns the packet size of the FTDI chip
+func (e *Emulator) MaxPacketSize() uint16 {
+	return 64
+}
+
+// SetReadTimeout sets how long Read waits for data before it returns the status bytes alone
+func (e *Emulator) SetReadTimeout(timeout time.Duration) {
+	e.mx.Lock()
+	e.readTimeout = timeout
+	e.mx.Unlock()
+}
+
+// Read returns a packet with the status bytes and the data that has been sent.
+// Like the FTDI chip, it returns the status bytes alone, if there is no data.
+func (e *Emulator) Read(b []byte) (int, error) {
+	if len(b) < len(ftdiStatus) {
+		return 0, io.ErrShortBuffer
+	}
+	e.mx.Lock()
+	timeout := time.NewTimer(e.readTimeout)
+	e.mx.Unlock()
+	defer timeout.Stop()
+
+	n := copy(b, ftdiStatus[:])
+	for {
+		e.mx.Lock()
+		if e.err != nil {
+			err := e.err
+			e.mx.Unlock()
+			return 0, err
+		}
+		if e.closed {
+			e.mx.Unlock()
+			return 0, io.ErrClosedPipe
+		}
+		if len(e.out) > 0 {
+			data := b[n:]
+			if e.split > 0 && len(data) > e.split {
+				data = data[:e.split]
+			}
+			got := copy(data, e.out)
+			e.out = e.out[got:]
+			e.mx.Unlock()
+			return n + got, nil
+		}
+		e.mx.Unlock()
+
+		select {
+		case <-e.changed:
+		case <-timeout.C:
+			return n, nil
+		}
+	}
+}
+
+// Write parses the messages and answers them
+func (e *Emulator) Write(b []byte) (int, error) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.closed {
+		return 0, io.ErrClosedPipe
+	}
+	e.in = append(e.in, b...)
+	for len(e.in) > 0 {
+		n := e.protocol.parse(e, e.in)
+		if n == 0 {
+			break
+		}
+		e.in = e.in[n:]
+	}
+	return len(b), nil
+}
+
+// Close closes the emulator, the following reads and writes fail
+func (e *Emulator) Close() error {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	e.closed = true
+	return e.err
+}
+
+// series is the protocol of the monome 64: messages of two bytes, the position in the second
+// byte, with the row in the high nibble and the reversed column in the low nibble
+type series struct{}
+
+func (series) parse(e *Emulator, in []byte) int {
+	switch in[0] & 0xf0 {
+	case 0x20, 0x30:
+		if len(in) < 2 {
+			return 0
+		}
+		var brightness uint8
+		if in[0]&0xf0 == 0x20 {
+			brightness = 15
+		}
+		e.setLight(in[1]>>4, changeY(in[1]&0x0f), brightness)
+		return 2
+	default:
+		// e.g. the query for the kind of the device, which the monome 64 does not answer
+		return 1
+	}
+}
+
+func (series) key(x, y uint8, down bool) []byte {
+	var state byte = 0x10
+	if down {
+		state = 0x00
+	}
+	return []byte{state, x<<4 | changeY(y)}
+}
+
+// mext is the protocol of the monome 128 and the later grids: a command byte, followed by
+// its arguments, the column before the row
+type mext struct{}
+
+// mextLengths are the lengths of the mext messages, including the command byte
+var mextLengths = map[byte]int{
+	0x00: 1,  // query
+	0x01: 1,  // query id
+	0x02: 33, // write id
+	0x05: 1,  // query grid size
+	0x10: 3,  // led off: x y
+	0x11: 3,  // led on: x y
+	0x12: 1,  // all off
+	0x13: 1,  // all on
+	0x14: 11, // map: x y, 8 rows of bits
+	0x15: 4,  // row: x y bits
+	0x16: 4,  // col: x y bits
+	0x17: 2,  // intensity
+	0x18: 4,  // level set: x y level
+	0x19: 2,  // level all: level
+	0x1A: 35, // level map: x y, 64 levels of 4 bits
+	0x1B: 7,  // level row: x y, 8 levels of 4 bits
+	0x1C: 7,  // level col: x y, 8 levels of 4 bits
+}
+
+func (mext) parse(e *Emulator, in []byte) int {
+	n, known := mextLengths[in[0]]
+	if !known {
+		return 1
+	}
+	if len(in) < n {
+		return 0
+	}
+	col, row := uint8(0), uint8(0)
+	if n >= 3 {
+		col, row = in[1], in[2]
+	}
+
+	switch in[0] {
+	case 0x00:
+		// one led grid and one key grid section, with a quadrant of 8x8 per 64 keys
+		quadrants := uint8(int(e.rows) * int(e.cols) / 64)
+		e.out = append(e.out, 0x00, 0x01, quadrants, 0x00, 0x02, quadrants)
+		e.notify()
+	case 0x01:
+		// the query for the id, answered with the id padded to 32 bytes
+		id := make([]byte, 33)
+		id[0] = 0x01
+		copy(id[1:], "monome 128")
+		e.out = append(e.out, id...)
+		e.notify()
+	case 0x05:
+		e.out = append(e.out, 0x03, e.cols, e.rows)
+		e.notify()
+	case 0x10, 0x11:
+		e.setLight(row, col, 15*(in[0]&1))
+	case 0x12, 0x13, 0x19:
+		brightness := 15 * (in[0] & 1)
+		if in[0] == 0x19 {
+			brightness = in[1]
+		}
+		for x := uint8(0); x < e.rows; x++ {
+			for y := uint8(0); y < e.cols; y++ {
+				e.setLight(x, y, brightness)
+			}
+		}
+	case 0x14:
+		for r, bits := range in[3:11] {
+			for c := 0; c < 8; c++ {
+				e.setLight(row+uint8(r), col+uint8(c), 15*(bits>>c&1))
+			}
+		}
+	case 0x15, 0x16:
+		for i := uint8(0); i < 8; i++ {
+			brightness := 15 * (in[3] >> i & 1)
+			if in[0] == 0x15 {
+				e.setLight(row, col+i, brightness)
+			} else {
+				e.setLight(row+i, col, brightness)
+			}
+		}
+	case 0x18:
+		e.setLight(row, col, in[3])
+	case 0x1A:
+		for i, level := range unpackLevels(in[3:35]) {
+			e.setLight(row+uint8(i/8), col+uint8(i%8), level)
+		}
+	case 0x1B, 0x1C:
+		for i, level := range unpackLevels(in[3:7]) {
+			if in[0] == 0x1B {
+				e.setLight(row, col+uint8(i), level)
+			} else {
+				e.setLight(row+uint8(i), col, level)
+			}
+		}
+	}
+	return n
+}
+
+// unpackLevels returns the levels of 4 bits, packed in the given bytes with the high nibble first
+func unpackLevels(b []byte) []uint8 {
+	levels := make([]uint8, 0, len(b)*2)
+	for _, v := range b {
+		levels = append(levels, v>>4, v&0x0f)
+	}
+	return levels
+}
+
+func (mext) key(x, y uint8, down bool) []byte {
+	var state byte = 0x20
+	if down {
+		state = 0x21
+	}
+	return []byte{state, y, x}
+}
@@ -0,0 +1,162 @@
+package monome
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pressed collects the keys passed to the handler
+type pressed struct {
+	mx   sync.Mutex
+	keys []KeyEvent
+}
+
+func (p *pressed) Handle(c Connection, x, y uint8, down bool) {
+	p.mx.Lock()
+	p.keys = append(p.keys, KeyEvent{X: x, Y: y, Down: down})
+	p.mx.Unlock()
+}
+
+// wait waits until n keys have been handled and returns them
+func (p *pressed) wait(t *testing.T, n int) []KeyEvent {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p.mx.Lock()
+		keys := append([]KeyEvent(nil), p.keys...)
+		p.mx.Unlock()
+		if len(keys) >= n {
+			return keys
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d keys, expected %d: %v", len(keys), n, keys)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// connectEmulator connects to the emulator and listens with the returned key log
+func connectEmulator(t *testing.T, e *Emulator) (Connection, *pressed) {
+	t.Helper()
+	c, err := ConnectTransport(e, HandshakeDelay(0), ReadTimeout(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("ConnectTransport() = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	var p pressed
+	c.SetHandler(&p)
+	c.StartListening(nil)
+	return c, &p
+}
+
+func expectKeys(t *testing.T, got []KeyEvent, expected ...KeyEvent) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("keys = %v, expected %v", got, expected)
+	}
+	for i := range expected {
+		if got[i].X != expected[i].X || got[i].Y != expected[i].Y || got[i].Down != expected[i].Down {
+			t.Errorf("key %d = %d,%d down: %v, expected %d,%d down: %v",
+				i, got[i].X, got[i].Y, got[i].Down, expected[i].X, expected[i].Y, expected[i].Down)
+		}
+	}
+}
+
+func TestConnectTransportIdentifies(t *testing.T) {
+	tests := []struct {
+		emulator   *Emulator
+		name       string
+		rows, cols uint8
+	}{
+		{Emulate64(), "monome64", 8, 8},
+		{Emulate128(), "monome128", 8, 16},
+	}
+
+	for _, test := range tests {
+		c, _ := connectEmulator(t, test.emulator)
+		if c.String() != test.name {
+			t.Errorf("String() = %q, expected %q", c.String(), test.name)
+		}
+		if c.Rows() != test.rows || c.Cols() != test.cols {
+			t.Errorf("%s: size = %dx%d, expected %dx%d", test.name, c.Rows(), c.Cols(), test.rows, test.cols)
+		}
+	}
+}
+
+func TestM64Nibbles(t *testing.T) {
+	e := Emulate64()
+	c, p := connectEmulator(t, e)
+
+	// every position, so that each nibble of the row and the column is used
+	var expected []KeyEvent
+	for x := uint8(0); x < 8; x++ {
+		for y := uint8(0); y < 8; y++ {
+			if err := c.Set(x, y, (x+y)%2*15); err != nil {
+				t.Fatalf("Set(%d, %d) = %v", x, y, err)
+			}
+			e.Press(x, y)
+			e.Release(x, y)
+			expected = append(expected, KeyEvent{X: x, Y: y, Down: true}, KeyEvent{X: x, Y: y})
+		}
+	}
+
+	for x := uint8(0); x < 8; x++ {
+		for y := uint8(0); y < 8; y++ {
+			if got, want := e.Light(x, y), (x+y)%2*15; got != want {
+				t.Errorf("light %d,%d = %d, expected %d", x, y, got, want)
+			}
+		}
+	}
+	expectKeys(t, p.wait(t, len(expected)), expected...)
+}
+
+func TestM128SplitReads(t *testing.T) {
+	e := Emulate128()
+	_, p := connectEmulator(t, e)
+
+	// each byte in its own read, after the identification
+	e.SplitReads(1)
+
+	e.Press(7, 15)
+	e.Release(7, 15)
+	e.Press(0, 9)
+	expectKeys(t, p.wait(t, 3),
+		KeyEvent{X: 7, Y: 15, Down: true},
+		KeyEvent{X: 7, Y: 15},
+		KeyEvent{X: 0, Y: 9, Down: true},
+	)
+}
+
+func TestEmulatorDropBytes(t *testing.T) {
+	for _, e := range []*Emulator{Emulate64(), Emulate128()} {
+		_, p := connectEmulator(t, e)
+
+		// the first byte of the press is lost, the rest must not be taken for a key
+		e.DropBytes(1)
+		e.Press(3, 5)
+		e.Press(4, 6)
+		e.Release(4, 6)
+		expectKeys(t, p.wait(t, 2),
+			KeyEvent{X: 4, Y: 6, Down: true},
+			KeyEvent{X: 4, Y: 6},
+		)
+	}
+}
+
+func TestEmulatorFail(t *testing.T) {
+	e := Emulate128()
+	c, _ := connectEmulator(t, e)
+
+	unplugged := errors.New("unplugged")
+	e.Fail(unplugged)
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done() not closed after Fail")
+	}
+	if !errors.Is(c.Err(), unplugged) {
+		t.Errorf("Err() = %v, expected %v", c.Err(), unplugged)
+	}
+}
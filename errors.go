@@ -1,12 +1,73 @@
 package monome
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/karalabe/gousb/usb"
 )
 
-var USBAccessError = fmt.Errorf("USB stack could not be opened. Probably missing rights. Try to run as admin.")
+// The sentinel errors of this package. The returned errors wrap them,
+// so use errors.Is to check for them.
+var (
+	// ErrClosed is returned, when using a connection that has been closed
+	ErrClosed = errors.New("connection closed")
+
+	// ErrDisconnected is returned, when the device is no longer attached
+	ErrDisconnected = errors.New("device disconnected")
+
+	// ErrOutOfRange is returned, if a position is outside of a device
+	ErrOutOfRange = errors.New("position out of range")
+
+	// ErrTimeout is returned, if the device did not respond in time
+	ErrTimeout = errors.New("timeout")
+
+	// ErrPermissionDenied is returned, if the USB stack or device could not be accessed
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrListening is returned, when trying to listen to a connection that is already listening
+	ErrListening = errors.New("already listening")
+)
+
+// USBAccessError is returned if the USB stack could not be opened. It wraps ErrPermissionDenied.
+var USBAccessError = fmt.Errorf("USB stack could not be opened (%w). Probably missing rights. Try to run as admin.", ErrPermissionDenied)
+
+// usbError wraps an error of the USB stack and maps it to the sentinel errors
+type usbError struct {
+	err      error
+	sentinel error
+}
+
+func (e usbError) Error() string {
+	return e.err.Error()
+}
+
+func (e usbError) Unwrap() error {
+	return e.err
+}
+
+func (e usbError) Is(target error) bool {
+	return e.sentinel != nil && target == e.sentinel
+}
+
+// wrapUSBError wraps errors of the USB stack, so that they can be checked against the sentinel errors
+func wrapUSBError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var sentinel error
+	switch err {
+	case usb.ERROR_TIMEOUT, usb.LIBUSB_TRANSFER_TIMED_OUT:
+		sentinel = ErrTimeout
+	case usb.ERROR_ACCESS:
+		sentinel = ErrPermissionDenied
+	case usb.ERROR_NO_DEVICE, usb.LIBUSB_TRANSFER_NO_DEVICE:
+		sentinel = ErrDisconnected
+	default:
+		return err
+	}
+	return usbError{err: err, sentinel: sentinel}
+}
 
 type UnknownMonomeError struct {
 	Response          []byte
@@ -16,9 +77,10 @@ type UnknownMonomeError struct {
 }
 
 func (e *UnknownMonomeError) Error() string {
-	return fmt.Sprintf("unknown monome kind (got % X (%s))\n", e.Response, string(e.Response))
+	return fmt.Sprintf("unknown monome kind (got % X (%s))", e.Response, string(e.Response))
 }
 
+// Error is an error that happened while setting the light at X/Y
 type Error struct {
 	X            uint8
 	Y            uint8
@@ -31,6 +93,12 @@ func (e Error) Error() string {
 	return fmt.Sprintf("device %q had the following error when trying to set %d/%d in order to %s: %v", e.Device, e.X, e.Y, e.Task, e.WrappedError)
 }
 
+func (e Error) Unwrap() error {
+	return e.WrappedError
+}
+
+// Errors collects the errors that happened while performing a task.
+// errors.Is and errors.As inspect all of the collected errors.
 type Errors struct {
 	Task   string
 	Errors []error
@@ -47,7 +115,32 @@ func (m *Errors) Add(err error) {
 }
 
 func (m *Errors) Error() string {
-	return fmt.Sprintf("%d errors happened while setting, typecast to monome.Errors to inspect them", m.Len())
+	task := m.Task
+	if task == "" {
+		task = "unknown task"
+	}
+	if m.Len() == 0 {
+		return fmt.Sprintf("no errors happened while trying to %s", task)
+	}
+	return fmt.Sprintf("%d error(s) happened while trying to %s, the first one: %v", m.Len(), task, m.Errors[0])
+}
+
+func (m *Errors) Unwrap() []error {
+	return m.Errors
+}
+
+// withTask sets the task of err, if it is an Error or *Errors and returns it
+func withTask(err error, task string) error {
+	switch e := err.(type) {
+	case Error:
+		e.Task = task
+		return e
+	case *Errors:
+		e.Task = task
+		return e
+	default:
+		return err
+	}
 }
 
 type _USBContextError string
@@ -56,12 +149,17 @@ func (e _USBContextError) Error() string {
 	return string(e)
 }
 
+// ConnectionClosedError is returned when using a closed connection. It wraps ErrClosed.
 type ConnectionClosedError string
 
 func (c ConnectionClosedError) Error() string {
 	return fmt.Sprintf("the connection to device %s is closed", string(c))
 }
 
+func (c ConnectionClosedError) Is(target error) bool {
+	return target == ErrClosed
+}
+
 type ConnectError struct {
 	USBDevice   *usb.Device
 	USBEndPoint struct {
@@ -79,6 +177,10 @@ func (m *ConnectError) Error() string {
 	return fmt.Sprintf("the following error happened while trying to connect to USB endpoint %d as %s: %v", m.USBEndPoint.Number, m.USBEndPoint.Purpose, m.WrappedError)
 }
 
+func (m *ConnectError) Unwrap() error {
+	return m.WrappedError
+}
+
 type CloseError struct {
 	Device       string
 	WrappedError error
@@ -88,6 +190,10 @@ func (e CloseError) Error() string {
 	return fmt.Sprintf("when closing device %q the following error occured: %v", e.Device, e.WrappedError)
 }
 
+func (e CloseError) Unwrap() error {
+	return e.WrappedError
+}
+
 type ReadError struct {
 	Device       string
 	WrappedError error
@@ -96,3 +202,36 @@ type ReadError struct {
 func (e ReadError) Error() string {
 	return fmt.Sprintf("when reading from device %q the following error occured: %v", e.Device, e.WrappedError)
 }
+
+func (e ReadError) Unwrap() error {
+	return e.WrappedError
+}
+
+// OutOfRangeError is returned when trying to set a position that is outside of the rows and columns of a device.
+// It wraps ErrOutOfRange.
+type OutOfRangeError struct {
+	Device string
+	X      uint8
+	Y      uint8
+	Rows   uint8
+	Cols   uint8
+}
+
+func (e OutOfRangeError) Error() string {
+	return fmt.Sprintf("position %d/%d is out of range for device %q with %d rows and %d cols", e.X, e.Y, e.Device, e.Rows, e.Cols)
+}
+
+func (e OutOfRangeError) Is(target error) bool {
+	return target == ErrOutOfRange
+}
+
+// checkRange returns an OutOfRangeError, if x,y is outside of the device.
+// x and y are interpreted in the convention of the device.
+func checkRange(d Device, x, y uint8) error {
+	p := ConventionOf(d).Point(x, y)
+	rows, cols := d.Rows(), d.Cols()
+	if p.Row < rows && p.Col < cols {
+		return nil
+	}
+	return OutOfRangeError{Device: d.String(), X: x, Y: y, Rows: rows, Cols: cols}
+}
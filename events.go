@@ -0,0 +1,434 @@
+package monome
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is something that happened on a connection.
+// The events are passed to an EventHandler (see Connection.SetEventHandler)
+// and to the channel returned by Connection.Events.
+type Event interface {
+	// Source returns the identity of the device the event comes from
+	Source() DeviceID
+
+	// Time returns when the event happened. It carries a monotonic clock reading,
+	// so the durations between events are not affected by changes of the wall clock.
+	Time() time.Time
+}
+
+// DeviceID identifies a device
+type DeviceID struct {
+	// Name is the name of the device (as returned by String)
+	Name string
+
+	// Serial is the serial number of the device, if it is known
+	Serial string
+}
+
+func (d DeviceID) String() string {
+	if d.Serial == "" {
+		return d.Name
+	}
+	return d.Name + " (" + d.Serial + ")"
+}
+
+// EventHeader holds the fields that all events have in common
+type EventHeader struct {
+	Device DeviceID
+	At     time.Time
+}
+
+func (e EventHeader) Source() DeviceID { return e.Device }
+func (e EventHeader) Time() time.Time  { return e.At }
+
+// header returns an EventHeader for the given device, stamped with the current time
+func header(d Device) EventHeader {
+	return EventHeader{Device: IDOf(d), At: time.Now()}
+}
+
+// identifier is implemented by devices that know more about their identity than their name
+type identifier interface {
+	ID() DeviceID
+}
+
+// IDOf returns the identity of the given device
+func IDOf(d Device) DeviceID {
+	if i, ok := d.(identifier); ok {
+		return i.ID()
+	}
+	return DeviceID{Name: d.String()}
+}
+
+// KeyEvent is the pressing (Down=true) or releasing (Down=false) of a key.
+// X and Y follow the convention of the connection.
+type KeyEvent struct {
+	EventHeader
+	X    uint8
+	Y    uint8
+	Down bool
+}
+
+// TiltEvent is a change of the tilt sensor N, for devices that have one
+type TiltEvent struct {
+	EventHeader
+	N uint8
+	X int16
+	Y int16
+	Z int16
+}
+
+// EncoderDeltaEvent is the turning of the encoder N by Delta, for devices that have encoders
+type EncoderDeltaEvent struct {
+	EventHeader
+	N     uint8
+	Delta int8
+}
+
+// EncoderKeyEvent is the pressing (Down=true) or releasing (Down=false) of the encoder N,
+// for devices that have encoders with keys
+type EncoderKeyEvent struct {
+	EventHeader
+	N    uint8
+	Down bool
+}
+
+// DeviceAddedEvent is the first event of every event channel.
+// At is the time the connection was made.
+type DeviceAddedEvent struct {
+	EventHeader
+}
+
+// DeviceRemovedEvent is the last event before the event channel is closed.
+// Err is the error that ended the connection or nil, if it was closed.
+type DeviceRemovedEvent struct {
+	EventHeader
+	Err error
+}
+
+// ErrorEvent reports an error that happened while listening to the device.
+// A Manager reports the errors while looking for devices with it; the handlers
+// get those with a nil Connection.
+type ErrorEvent struct {
+	EventHeader
+	Err error
+}
+
+// ReconnectingEvent reports that the device has been lost and the connection tries
+// to reopen it (see AutoReconnect). Err is the error that caused the loss.
+type ReconnectingEvent struct {
+	EventHeader
+	Err error
+}
+
+// ReconnectedEvent reports that the device has been reopened and its lights have been restored
+type ReconnectedEvent struct {
+	EventHeader
+}
+
+// ListeningStoppedEvent reports that the listening to the device has stopped.
+// The keys that are held are not released by key events anymore.
+type ListeningStoppedEvent struct {
+	EventHeader
+}
+
+// EventHandler responds to the events of a connection
+type EventHandler interface {
+	HandleEvent(c Connection, ev Event)
+}
+
+// EventHandlerFunc is a function that acts as an EventHandler
+type EventHandlerFunc func(c Connection, ev Event)
+
+func (h EventHandlerFunc) HandleEvent(c Connection, ev Event) {
+	h(c, ev)
+}
+
+// Resetter is implemented by handlers that keep state for the held keys, e.g. timers.
+// Reset discards the state for c, when the held keys won't be released by key events,
+// because the listening has stopped or the device has been removed.
+type Resetter interface {
+	Reset(c Connection)
+}
+
+// KeyHandler returns an EventHandler that passes the key events to the given Handler
+// and ignores all other events. If the Handler is a Resetter, it is reset by
+// the ListeningStoppedEvent and the DeviceRemovedEvent.
+func KeyHandler(h Handler) EventHandler {
+	return keyHandler{h}
+}
+
+type keyHandler struct {
+	Handler
+}
+
+func (k keyHandler) HandleEvent(c Connection, ev Event) {
+	switch e := ev.(type) {
+	case KeyEvent:
+		k.Handle(c, e.X, e.Y, e.Down)
+	case ListeningStoppedEvent, DeviceRemovedEvent:
+		if r, ok := k.Handler.(Resetter); ok {
+			r.Reset(c)
+		}
+	}
+}
+
+// OverflowPolicy defines what happens, if the event buffer is full
+type OverflowPolicy uint8
+
+const (
+	// DropOldest discards the oldest buffered event to make room for the new one
+	DropOldest OverflowPolicy = iota
+
+	// DropNewest discards the new event
+	DropNewest
+
+	// Block waits until there is room in the buffer. This stops the reading
+	// from the device until the events are consumed. When the listening stops,
+	// the waiting event is dropped.
+	Block
+)
+
+// DefaultEventBuffer is the default size of the event buffer
+const DefaultEventBuffer = 64
+
+// EventBuffer sets the size of the buffer of the event channel and what happens
+// when it is full. The default is a buffer of DefaultEventBuffer events and DropOldest.
+func EventBuffer(size int, policy OverflowPolicy) Option {
+	return func(m *connection) {
+		m.events.size = size
+		m.events.policy = policy
+	}
+}
+
+// eventQueue is a lazily created event channel with an overflow policy
+type eventQueue struct {
+	mx        sync.RWMutex
+	ch        chan Event
+	size      int
+	policy    OverflowPolicy
+	closed    bool
+	done      chan struct{}
+	doneOnce  sync.Once
+	closeOnce sync.Once
+
+	// stop is the stop channel of the listening, it wakes up a blocked publish
+	smx  sync.Mutex
+	stop chan struct{}
+}
+
+// setStop sets the stop channel of the listening
+func (q *eventQueue) setStop(stop chan struct{}) {
+	q.smx.Lock()
+	q.stop = stop
+	q.smx.Unlock()
+}
+
+// doneChan is closed, when the queue is closed
+func (q *eventQueue) doneChan() chan struct{} {
+	q.doneOnce.Do(func() {
+		q.done = make(chan struct{})
+	})
+	return q.done
+}
+
+// channel returns the channel and creates it on the first call, starting with the given events
+func (q *eventQueue) channel(first ...Event) <-chan Event {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if q.ch != nil {
+		return q.ch
+	}
+	size := q.size
+	if size <= 0 {
+		size = DefaultEventBuffer
+	}
+	q.ch = make(chan Event, size)
+	if q.closed {
+		close(q.ch)
+		return q.ch
+	}
+	for _, ev := range first {
+		q.offer(ev)
+	}
+	return q.ch
+}
+
+// publish sends the event according to the overflow policy, if the channel has been created
+func (q *eventQueue) publish(ev Event) {
+	q.mx.RLock()
+	defer q.mx.RUnlock()
+	if q.ch == nil || q.closed {
+		return
+	}
+	switch q.policy {
+	case Block:
+		q.smx.Lock()
+		stop := q.stop
+		q.smx.Unlock()
+		select {
+		case q.ch <- ev:
+		case <-q.doneChan():
+		case <-stop:
+		}
+	case DropNewest:
+		select {
+		case q.ch <- ev:
+		default:
+		}
+	default:
+		q.offer(ev)
+	}
+}
+
+// offer sends the event without blocking, dropping the oldest event if necessary.
+// It must be called with a lock held.
+func (q *eventQueue) offer(ev Event) {
+	select {
+	case q.ch <- ev:
+		return
+	default:
+	}
+	select {
+	case <-q.ch:
+	default:
+	}
+	select {
+	case q.ch <- ev:
+	default:
+	}
+}
+
+// close sends the last event (if not nil) and closes the channel
+func (q *eventQueue) close(last Event) {
+	q.closeOnce.Do(func() {
+		// wake up blocked publishers
+		close(q.doneChan())
+		q.mx.Lock()
+		q.closed = true
+		if q.ch != nil {
+			if last != nil {
+				q.offer(last)
+			}
+			close(q.ch)
+		}
+		q.mx.Unlock()
+	})
+}
+
+// dispatcher passes the events of a connection to the event handler and the event channel
+type dispatcher struct {
+	dmx         sync.RWMutex
+	h           EventHandler
+	subs        []*subscription
+	kmx         sync.Mutex
+	keys        keyState
+	events      eventQueue
+	connectedAt time.Time
+	removed     sync.Once
+}
+
+func newDispatcher() dispatcher {
+	return dispatcher{connectedAt: time.Now()}
+}
+
+// SetHandler sets the handler for the key events
+func (d *dispatcher) SetHandler(h Handler) {
+	if h == nil {
+		d.SetEventHandler(nil)
+		return
+	}
+	d.SetEventHandler(KeyHandler(h))
+}
+
+// SetEventHandler sets the handler for all events
+func (d *dispatcher) SetEventHandler(h EventHandler) {
+	d.dmx.Lock()
+	d.h = h
+	d.dmx.Unlock()
+}
+
+// dispatch passes the event to the event channel, the subscriptions and the handler.
+// It returns false, if no handler got the event.
+func (d *dispatcher) dispatch(c Connection, ev Event) bool {
+	if ke, ok := ev.(KeyEvent); ok {
+		d.trackKey(ke)
+	}
+	d.events.publish(ev)
+	return d.deliver(c, ev)
+}
+
+// deliver passes the event to the subscriptions and the handler, until the propagation is stopped.
+// It returns false, if no handler got the event.
+func (d *dispatcher) deliver(c Connection, ev Event) bool {
+	d.dmx.RLock()
+	subs, h := d.subs, d.h
+	d.dmx.RUnlock()
+
+	var handled bool
+	for _, s := range subs {
+		if !s.accepts(c, ev) {
+			continue
+		}
+		handled = true
+		if s.deliver(c, ev) {
+			return true
+		}
+	}
+	if h == nil {
+		return handled
+	}
+	h.HandleEvent(c, ev)
+	return true
+}
+
+// handle dispatches a key event
+func (d *dispatcher) handle(c Connection, x, y uint8, down bool) bool {
+	return d.dispatch(c, KeyEvent{EventHeader: header(c), X: x, Y: y, Down: down})
+}
+
+func (d *dispatcher) eventsOf(c Connection) <-chan Event {
+	return d.events.channel(DeviceAddedEvent{EventHeader{Device: IDOf(c), At: d.connectedAt}})
+}
+
+// closeEvents passes the DeviceRemovedEvent to the handlers and closes the event channel.
+// Only the first call has an effect.
+func (d *dispatcher) closeEvents(c Connection, err error) {
+	d.removed.Do(func() {
+		d.resetKeys()
+		ev := DeviceRemovedEvent{EventHeader: header(c), Err: err}
+		d.deliver(c, ev)
+		d.events.close(ev)
+	})
+}
+
+// composite is a connection that is made of other connections
+type composite interface {
+	Connection
+	dispatch(c Connection, ev Event) bool
+	resetKeys()
+}
+
+// memberHandler returns the EventHandler that is subscribed to a member of the composite connection c.
+// Key events are mapped to the coordinates of c via toComposite, the DeviceAddedEvent and
+// DeviceRemovedEvent of the member are swallowed and all other events are passed on.
+// When a member is removed, the held keys of c are forgotten.
+func memberHandler(c composite, toComposite func(Point) (x, y uint8)) EventHandler {
+	return EventHandlerFunc(func(member Connection, ev Event) {
+		switch e := ev.(type) {
+		case KeyEvent:
+			e.X, e.Y = toComposite(ConventionOf(member).Point(e.X, e.Y))
+			e.Device = IDOf(c)
+			c.dispatch(c, e)
+		case DeviceAddedEvent:
+		case DeviceRemovedEvent:
+			c.resetKeys()
+		case ListeningStoppedEvent:
+			c.resetKeys()
+			e.Device = IDOf(c)
+			c.dispatch(c, e)
+		default:
+			c.dispatch(c, ev)
+		}
+	})
+}
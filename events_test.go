@@ -0,0 +1,44 @@
+package monome
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloseWithFullBlockingEventBuffer(t *testing.T) {
+	v := NewVirtual(2, 2, EventBuffer(1, Block), ReadTimeout(5*time.Millisecond))
+	// the channel is created, but never read from
+	v.Events()
+	v.StartListening(nil)
+	v.Press(0, 0)
+	v.Press(1, 1)
+	// let the listening block on the full channel
+	time.Sleep(20 * time.Millisecond)
+
+	// the listening must end, though the handler of the key is waiting for room
+	v.StopListening()
+	deadline := time.Now().Add(time.Second)
+	for {
+		v.mx.RLock()
+		listening := v.stopped != nil
+		v.mx.RUnlock()
+		if !listening {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the listening did not stop while waiting for room in the event buffer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error)
+	go func() { closed <- v.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close() blocked on the full event buffer")
+	}
+}
@@ -1,10 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
-	"runtime"
 	"sort"
 	"time"
 
@@ -13,9 +13,8 @@ import (
 )
 
 var (
-	connections      = map[string]monome.Connection{}
+	connections      = map[monome.DeviceID]monome.Connection{}
 	sigchan          = make(chan os.Signal, 10)
-	stopScanning     = make(chan bool)
 	cleanup          = make(chan bool)
 	removeConnection = make(chan monome.Connection, 4)
 	addConnection    = make(chan monome.Connection, 4)
@@ -54,9 +53,12 @@ func manageConnections() {
 			conn.StopListening()
 			time.Sleep(time.Millisecond * 30)
 			conn.Close()
-			delete(connections, conn.String())
+			delete(connections, monome.IDOf(conn))
 		case conn := <-addConnection:
-			connections[conn.String()] = conn
+			if _, has := connections[monome.IDOf(conn)]; has {
+				continue
+			}
+			connections[monome.IDOf(conn)] = conn
 			go func(d monome.Connection) {
 				err := initConnection(d)
 				if err != nil {
@@ -72,36 +74,30 @@ func manageConnections() {
 				conn.Close()
 			}
 			return
-		default:
-			runtime.Gosched()
 		}
 	}
 }
 
-func scanForConnections() {
-	t := time.NewTicker(time.Second)
-	for {
-		select {
-		case <-stopScanning:
-			fmt.Println("stop scanning")
-			t.Stop()
-			return
-		case <-t.C:
-			conns, err := monome.Connections()
-			
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "ERROR: %v",err)
-				os.Exit(1)
-			}
-
-			for _, conn := range conns {
-				addConnection <- conn
-			}
-		default:
-			runtime.Gosched()
-
+// scanForConnections adds and removes the connections as the devices are plugged in and out,
+// until ctx is done
+func scanForConnections(ctx context.Context) {
+	manager := monome.NewManager(time.Second)
+	manager.SetEventHandler(monome.EventHandlerFunc(func(conn monome.Connection, ev monome.Event) {
+		switch e := ev.(type) {
+		case monome.DeviceAddedEvent:
+			addConnection <- conn
+		case monome.DeviceRemovedEvent:
+			removeConnection <- conn
+		case monome.ErrorEvent:
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", e.Err)
 		}
+	}))
+	err := manager.Run(ctx)
+	if err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
 	}
+	fmt.Println("stop scanning")
 }
 
 type Connections []monome.Connection
@@ -155,7 +151,12 @@ func run() error {
 
 	case scanCommand:
 		go manageConnections()
-		go scanForConnections()
+		ctx, stopScanning := context.WithCancel(context.Background())
+		scanning := make(chan bool)
+		go func() {
+			scanForConnections(ctx)
+			close(scanning)
+		}()
 
 		// listen for ctrl+c
 		go signal.Notify(sigchan, os.Interrupt)
@@ -164,7 +165,8 @@ func run() error {
 		<-sigchan
 
 		fmt.Fprint(os.Stdout, "\ninterrupted, cleaning up...")
-		stopScanning <- true
+		stopScanning()
+		<-scanning
 		cleanup <- true
 		fmt.Fprint(os.Stdout, "done\n")
 		os.Exit(0)
@@ -204,7 +206,7 @@ func main() {
 	err := run()
 
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "ERROR: %v\n", err)
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		os.Exit(1)
 	}
 
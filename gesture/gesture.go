@@ -0,0 +1,371 @@
+// Package gesture recognizes gestures like long presses, double taps, chords and swipes
+// in the key events of monome connections.
+//
+// A Recognizer consumes the key events of one or more connections and passes the
+// recognized gestures as events to a monome.EventHandler. The keys of the gestures are
+// monome.Points, so they don't depend on the convention of the connection. For connections
+// that are made of several devices (e.g. monome.RowConnection), the combined coordinates are used.
+//
+// The gestures don't exclude each other: a long press of a key of a chord is still reported
+// and the keys of a fast swipe might also form a chord.
+package gesture
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gomonome/monome"
+)
+
+// Config holds the thresholds of the recognizer. Zero values are replaced by the defaults.
+type Config struct {
+	// Tap is the longest press that counts as a tap (default 250ms)
+	Tap time.Duration
+
+	// DoubleTap is the longest time between two taps of a double tap (default 300ms)
+	DoubleTap time.Duration
+
+	// LongPress is the time after which a held key is a long press (default 600ms)
+	LongPress time.Duration
+
+	// Chord is the longest time between the first and the last press of a chord (default 100ms)
+	Chord time.Duration
+
+	// SwipeStep is the longest time between two neighboring keys of a swipe (default 150ms)
+	SwipeStep time.Duration
+
+	// SwipeLength is the number of keys a swipe has at least (default 3)
+	SwipeLength int
+}
+
+// DefaultConfig holds the default thresholds
+var DefaultConfig = Config{
+	Tap:         250 * time.Millisecond,
+	DoubleTap:   300 * time.Millisecond,
+	LongPress:   600 * time.Millisecond,
+	Chord:       100 * time.Millisecond,
+	SwipeStep:   150 * time.Millisecond,
+	SwipeLength: 3,
+}
+
+func (c Config) withDefaults() Config {
+	if c.Tap <= 0 {
+		c.Tap = DefaultConfig.Tap
+	}
+	if c.DoubleTap <= 0 {
+		c.DoubleTap = DefaultConfig.DoubleTap
+	}
+	if c.LongPress <= 0 {
+		c.LongPress = DefaultConfig.LongPress
+	}
+	if c.Chord <= 0 {
+		c.Chord = DefaultConfig.Chord
+	}
+	if c.SwipeStep <= 0 {
+		c.SwipeStep = DefaultConfig.SwipeStep
+	}
+	if c.SwipeLength < 2 {
+		c.SwipeLength = DefaultConfig.SwipeLength
+	}
+	return c
+}
+
+// Tap is a short press and release of a key
+type Tap struct {
+	monome.EventHeader
+	Key monome.Point
+}
+
+// DoubleTap is the second of two taps of the same key in short succession
+type DoubleTap struct {
+	monome.EventHeader
+	Key monome.Point
+}
+
+// LongPress is reported, when a key has been held for Config.LongPress
+type LongPress struct {
+	monome.EventHeader
+	Key monome.Point
+}
+
+// Chord is reported for every key that is pressed shortly after other keys that are still held.
+// Keys are the held keys in the order of their presses.
+type Chord struct {
+	monome.EventHeader
+	Keys []monome.Point
+}
+
+// Direction is the direction of a swipe
+type Direction uint8
+
+const (
+	// Right is the direction of increasing columns
+	Right Direction = iota
+
+	// Left is the direction of decreasing columns
+	Left
+
+	// Down is the direction of increasing rows
+	Down
+
+	// Up is the direction of decreasing rows
+	Up
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Right:
+		return "right"
+	case Left:
+		return "left"
+	case Down:
+		return "down"
+	case Up:
+		return "up"
+	default:
+		return "unknown"
+	}
+}
+
+// step returns the point next to p in the direction d and false, if there is none
+func (d Direction) step(p monome.Point) (monome.Point, bool) {
+	switch d {
+	case Right:
+		return monome.Point{Row: p.Row, Col: p.Col + 1}, p.Col < 255
+	case Left:
+		return monome.Point{Row: p.Row, Col: p.Col - 1}, p.Col > 0
+	case Down:
+		return monome.Point{Row: p.Row + 1, Col: p.Col}, p.Row < 255
+	default:
+		return monome.Point{Row: p.Row - 1, Col: p.Col}, p.Row > 0
+	}
+}
+
+// Swipe is the pressing of neighboring keys along a row or column in quick succession.
+// It is reported when the swipe has Config.SwipeLength keys and again for every further key.
+type Swipe struct {
+	monome.EventHeader
+	From      monome.Point
+	To        monome.Point
+	Direction Direction
+}
+
+// Len returns the number of keys of the swipe
+func (s Swipe) Len() int {
+	if s.From.Row == s.To.Row {
+		return distance(s.From.Col, s.To.Col) + 1
+	}
+	return distance(s.From.Row, s.To.Row) + 1
+}
+
+func distance(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+var (
+	_ monome.Handler      = &Recognizer{}
+	_ monome.EventHandler = &Recognizer{}
+	_ monome.Resetter     = &Recognizer{}
+)
+
+// Recognizer recognizes gestures in key events and passes them to its handler.
+// It is a monome.Handler and a monome.EventHandler, so it can be set as the handler of
+// a connection or subscribed to it (see Attach). The handler might be called from a timer
+// goroutine (for long presses).
+//
+// The state of the recognition is kept per device (see monome.IDOf) and discarded,
+// when the listening stops or the device is removed.
+type Recognizer struct {
+	cfg     Config
+	h       monome.EventHandler
+	mx      sync.Mutex
+	devices map[monome.DeviceID]*state
+}
+
+// state is the state of the recognition for one device
+type state struct {
+	held    []*press
+	lastTap map[monome.Point]time.Time
+	swipe   []monome.Point
+	dir     Direction
+	swipeAt time.Time
+}
+
+type press struct {
+	key   monome.Point
+	at    time.Time
+	timer *time.Timer
+	long  bool
+	chord bool
+}
+
+// New returns a Recognizer that passes the gestures to h
+func New(cfg Config, h monome.EventHandler) *Recognizer {
+	return &Recognizer{
+		cfg:     cfg.withDefaults(),
+		h:       h,
+		devices: map[monome.DeviceID]*state{},
+	}
+}
+
+// Attach subscribes the recognizer to the key events of c.
+// The returned function removes the subscription.
+func (r *Recognizer) Attach(c monome.Connection) (detach func()) {
+	return c.Subscribe(r, monome.OnlyEvents(monome.KeyEvent{}, monome.ListeningStoppedEvent{}, monome.DeviceRemovedEvent{}))
+}
+
+// Handle passes a key event to the recognizer, with the current time
+func (r *Recognizer) Handle(c monome.Connection, x, y uint8, down bool) {
+	r.key(c, monome.ConventionOf(c).Point(x, y), down, time.Now())
+}
+
+// HandleEvent passes the key events to the recognizer. When the listening stops or
+// the device is removed, its state is discarded.
+func (r *Recognizer) HandleEvent(c monome.Connection, ev monome.Event) {
+	switch e := ev.(type) {
+	case monome.KeyEvent:
+		r.key(c, monome.ConventionOf(c).Point(e.X, e.Y), e.Down, e.At)
+	case monome.ListeningStoppedEvent, monome.DeviceRemovedEvent:
+		r.Reset(c)
+	}
+}
+
+// Reset discards the state of the recognition for the device of the given connection
+func (r *Recognizer) Reset(c monome.Connection) {
+	id := monome.IDOf(c)
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if st, has := r.devices[id]; has {
+		for _, p := range st.held {
+			p.timer.Stop()
+		}
+		delete(r.devices, id)
+	}
+}
+
+func (r *Recognizer) key(c monome.Connection, p monome.Point, down bool, at time.Time) {
+	id := monome.IDOf(c)
+	r.mx.Lock()
+	st, has := r.devices[id]
+	if !has {
+		st = &state{lastTap: map[monome.Point]time.Time{}}
+		r.devices[id] = st
+	}
+	var events []monome.Event
+	if down {
+		events = r.down(c, st, p, at)
+	} else {
+		events = r.up(c, st, p, at)
+	}
+	r.mx.Unlock()
+
+	for _, ev := range events {
+		r.h.HandleEvent(c, ev)
+	}
+}
+
+func (r *Recognizer) down(c monome.Connection, st *state, p monome.Point, at time.Time) (events []monome.Event) {
+	header := monome.EventHeader{Device: monome.IDOf(c), At: at}
+
+	pr := &press{key: p, at: at}
+	pr.timer = time.AfterFunc(r.cfg.LongPress, func() { r.longPress(c, pr) })
+
+	// chord
+	if len(st.held) > 0 && at.Sub(st.held[0].at) <= r.cfg.Chord {
+		keys := make([]monome.Point, 0, len(st.held)+1)
+		for _, h := range st.held {
+			h.chord = true
+			keys = append(keys, h.key)
+		}
+		pr.chord = true
+		events = append(events, Chord{EventHeader: header, Keys: append(keys, p)})
+	}
+	st.held = append(st.held, pr)
+
+	// swipe
+	r.extendSwipe(st, p, at)
+	if len(st.swipe) >= r.cfg.SwipeLength {
+		events = append(events, Swipe{EventHeader: header, From: st.swipe[0], To: p, Direction: st.dir})
+	}
+	return events
+}
+
+// extendSwipe adds p to the current swipe, if it continues it, otherwise p starts a new swipe
+func (r *Recognizer) extendSwipe(st *state, p monome.Point, at time.Time) {
+	n := len(st.swipe)
+	if n == 0 || at.Sub(st.swipeAt) > r.cfg.SwipeStep {
+		st.swipe = []monome.Point{p}
+		st.swipeAt = at
+		return
+	}
+	last := st.swipe[n-1]
+	if n == 1 {
+		for d := Right; d <= Up; d++ {
+			if next, ok := d.step(last); ok && next == p {
+				st.dir = d
+				st.swipe = append(st.swipe, p)
+				st.swipeAt = at
+				return
+			}
+		}
+	} else if next, ok := st.dir.step(last); ok && next == p {
+		st.swipe = append(st.swipe, p)
+		st.swipeAt = at
+		return
+	}
+	st.swipe = []monome.Point{p}
+	st.swipeAt = at
+}
+
+func (r *Recognizer) up(c monome.Connection, st *state, p monome.Point, at time.Time) (events []monome.Event) {
+	var pr *press
+	for i, h := range st.held {
+		if h.key == p {
+			pr = h
+			st.held = append(st.held[:i:i], st.held[i+1:]...)
+			break
+		}
+	}
+	if pr == nil {
+		// the press happened before the recognizer got the events
+		return nil
+	}
+	pr.timer.Stop()
+
+	if pr.long || pr.chord || at.Sub(pr.at) > r.cfg.Tap {
+		return nil
+	}
+
+	header := monome.EventHeader{Device: monome.IDOf(c), At: at}
+	if last, has := st.lastTap[p]; has && at.Sub(last) <= r.cfg.DoubleTap {
+		delete(st.lastTap, p)
+		return []monome.Event{DoubleTap{EventHeader: header, Key: p}}
+	}
+	st.lastTap[p] = at
+	return []monome.Event{Tap{EventHeader: header, Key: p}}
+}
+
+func (r *Recognizer) longPress(c monome.Connection, pr *press) {
+	r.mx.Lock()
+	st, has := r.devices[monome.IDOf(c)]
+	var held bool
+	if has {
+		for _, h := range st.held {
+			if h == pr {
+				held = true
+				break
+			}
+		}
+	}
+	if held {
+		pr.long = true
+	}
+	r.mx.Unlock()
+
+	if held {
+		r.h.HandleEvent(c, LongPress{EventHeader: monome.EventHeader{Device: monome.IDOf(c), At: time.Now()}, Key: pr.key})
+	}
+}
@@ -0,0 +1,188 @@
+package gesture
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomonome/monome"
+)
+
+// testConfig has thresholds that leave room for the scheduling of the test
+var testConfig = Config{
+	Tap:         100 * time.Millisecond,
+	DoubleTap:   150 * time.Millisecond,
+	LongPress:   80 * time.Millisecond,
+	Chord:       40 * time.Millisecond,
+	SwipeStep:   100 * time.Millisecond,
+	SwipeLength: 3,
+}
+
+// gestures collects the recognized gestures
+type gestures struct {
+	mx     sync.Mutex
+	events []monome.Event
+}
+
+func (g *gestures) HandleEvent(c monome.Connection, ev monome.Event) {
+	g.mx.Lock()
+	g.events = append(g.events, ev)
+	g.mx.Unlock()
+}
+
+// after returns the gestures after the given time, with the headers cleared for comparing
+func (g *gestures) after(d time.Duration) []monome.Event {
+	time.Sleep(d)
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	var events []monome.Event
+	for _, ev := range g.events {
+		switch e := ev.(type) {
+		case Tap:
+			e.EventHeader = monome.EventHeader{}
+			ev = e
+		case DoubleTap:
+			e.EventHeader = monome.EventHeader{}
+			ev = e
+		case LongPress:
+			e.EventHeader = monome.EventHeader{}
+			ev = e
+		case Chord:
+			e.EventHeader = monome.EventHeader{}
+			ev = e
+		case Swipe:
+			e.EventHeader = monome.EventHeader{}
+			ev = e
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// play runs the steps on a virtual grid with a recognizer and returns the recognized gestures
+func play(t *testing.T, attach func(v *monome.Virtual, r *Recognizer), steps ...monome.KeyStep) []monome.Event {
+	t.Helper()
+	v := monome.NewVirtual(8, 8, monome.ReadTimeout(5*time.Millisecond))
+	defer v.Close()
+
+	var g gestures
+	r := New(testConfig, &g)
+	attach(v, r)
+	v.StartListening(nil)
+	if err := v.Sequence(steps...); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Settle(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	return g.after(testConfig.LongPress + 20*time.Millisecond)
+}
+
+func subscribed(v *monome.Virtual, r *Recognizer) { r.Attach(v) }
+
+func key(x, y uint8, down bool, wait time.Duration) monome.KeyStep {
+	return monome.KeyStep{X: x, Y: y, Down: down, Wait: wait}
+}
+
+func expect(t *testing.T, got []monome.Event, expected ...monome.Event) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("gestures = %#v, expected %#v", got, expected)
+	}
+}
+
+func TestTap(t *testing.T) {
+	got := play(t, subscribed,
+		key(1, 2, true, 0),
+		key(1, 2, false, 10*time.Millisecond),
+	)
+	expect(t, got, Tap{Key: monome.Point{Row: 1, Col: 2}})
+}
+
+func TestDoubleTap(t *testing.T) {
+	p := monome.Point{Row: 3, Col: 3}
+	got := play(t, subscribed,
+		key(3, 3, true, 0),
+		key(3, 3, false, 10*time.Millisecond),
+		key(3, 3, true, 20*time.Millisecond),
+		key(3, 3, false, 10*time.Millisecond),
+	)
+	expect(t, got, Tap{Key: p}, DoubleTap{Key: p})
+}
+
+func TestLongPress(t *testing.T) {
+	got := play(t, subscribed,
+		key(0, 7, true, 0),
+		key(0, 7, false, testConfig.LongPress+30*time.Millisecond),
+	)
+	expect(t, got, LongPress{Key: monome.Point{Row: 0, Col: 7}})
+}
+
+func TestChord(t *testing.T) {
+	a, b := monome.Point{Row: 1, Col: 1}, monome.Point{Row: 4, Col: 6}
+	got := play(t, subscribed,
+		key(1, 1, true, 0),
+		key(4, 6, true, 5*time.Millisecond),
+		key(1, 1, false, 10*time.Millisecond),
+		key(4, 6, false, 0),
+	)
+	expect(t, got, Chord{Keys: []monome.Point{a, b}})
+}
+
+func TestSwipe(t *testing.T) {
+	got := play(t, subscribed,
+		key(2, 5, true, 0),
+		key(2, 5, false, 0),
+		key(3, 5, true, 20*time.Millisecond),
+		key(3, 5, false, 0),
+		key(4, 5, true, 20*time.Millisecond),
+		key(4, 5, false, 0),
+	)
+	// the keys are released right away, so each of them is a tap, too
+	expect(t, got,
+		Tap{Key: monome.Point{Row: 2, Col: 5}},
+		Tap{Key: monome.Point{Row: 3, Col: 5}},
+		Swipe{From: monome.Point{Row: 2, Col: 5}, To: monome.Point{Row: 4, Col: 5}, Direction: Down},
+		Tap{Key: monome.Point{Row: 4, Col: 5}},
+	)
+}
+
+func TestTapBehindMux(t *testing.T) {
+	got := play(t, func(v *monome.Virtual, r *Recognizer) {
+		mux := monome.NewMux()
+		mux.HandleRect(4, 4, 4, 4, r)
+		v.SetHandler(mux)
+	},
+		key(5, 6, true, 0),
+		key(5, 6, false, 10*time.Millisecond),
+		key(5, 6, true, 20*time.Millisecond),
+		key(5, 6, false, 10*time.Millisecond),
+	)
+	// the coordinates are relative to the region
+	p := monome.Point{Row: 1, Col: 2}
+	expect(t, got, Tap{Key: p}, DoubleTap{Key: p})
+}
+
+func TestResetWhenListeningStops(t *testing.T) {
+	v := monome.NewVirtual(8, 8, monome.ReadTimeout(5*time.Millisecond))
+	defer v.Close()
+
+	var g gestures
+	r := New(testConfig, &g)
+	v.SetHandler(r)
+	v.StartListening(nil)
+	v.Press(2, 2)
+	if err := v.Settle(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	v.StopListening()
+
+	expect(t, g.after(testConfig.LongPress+30*time.Millisecond))
+	r.mx.Lock()
+	n := len(r.devices)
+	r.mx.Unlock()
+	if n != 0 {
+		t.Errorf("state of %d devices kept after the listening stopped", n)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64)
+
+// Package term switches terminals and serial ports to raw mode.
+//
+// It is restricted to the architectures where the termios flags of package syscall
+// have been checked, e.g. the baud rate mask differs on ppc64.
+package term
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// cbaud is the mask of the baud rate in the control flags, it is missing in package syscall.
+// It is 0010017 on x86 and on the architectures that use the generic termbits.
+const cbaud = 0x100f
+
+// MakeRaw switches the terminal to raw mode and returns the previous termios.
+// If speed is not 0, the baud rate is set to it, e.g. syscall.B115200.
+func MakeRaw(fd uintptr, speed uint32) (syscall.Termios, error) {
+	var old syscall.Termios
+	if err := Ioctl(fd, syscall.TCGETS, unsafe.Pointer(&old)); err != nil {
+		return old, err
+	}
+	t := old
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	if speed != 0 {
+		t.Cflag &^= cbaud
+		t.Cflag |= speed
+		t.Ispeed, t.Ospeed = speed, speed
+	}
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	return old, Ioctl(fd, syscall.TCSETS, unsafe.Pointer(&t))
+}
+
+// Restore sets the termios that have been returned by MakeRaw
+func Restore(fd uintptr, t syscall.Termios) error {
+	return Ioctl(fd, syscall.TCSETS, unsafe.Pointer(&t))
+}
+
+// Ioctl calls the ioctl system call with the given request and argument
+func Ioctl(fd, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package monome
+
+import "time"
+
+// HeldKey is a key that is currently held down.
+// X and Y follow the convention of the connection.
+type HeldKey struct {
+	X     uint8
+	Y     uint8
+	Since time.Time
+}
+
+// keyState tracks the held keys of a connection, in the order of their presses
+type keyState struct {
+	held []HeldKey
+}
+
+// trackKey updates the held keys with the given key event
+func (d *dispatcher) trackKey(e KeyEvent) {
+	d.kmx.Lock()
+	defer d.kmx.Unlock()
+	for i, k := range d.keys.held {
+		if k.X == e.X && k.Y == e.Y {
+			d.keys.held = append(d.keys.held[:i:i], d.keys.held[i+1:]...)
+			break
+		}
+	}
+	if e.Down {
+		d.keys.held = append(d.keys.held, HeldKey{X: e.X, Y: e.Y, Since: e.At})
+	}
+}
+
+// resetKeys forgets all held keys, e.g. because the listening stopped
+// and the releases would not be noticed
+func (d *dispatcher) resetKeys() {
+	d.kmx.Lock()
+	d.keys.held = nil
+	d.kmx.Unlock()
+}
+
+// IsDown returns wether the key at x,y is held down
+func (d *dispatcher) IsDown(x, y uint8) bool {
+	_, down := d.PressedAt(x, y)
+	return down
+}
+
+// PressedAt returns when the key at x,y has been pressed and false, if it is not held down
+func (d *dispatcher) PressedAt(x, y uint8) (time.Time, bool) {
+	d.kmx.Lock()
+	defer d.kmx.Unlock()
+	for _, k := range d.keys.held {
+		if k.X == x && k.Y == y {
+			return k.Since, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// HeldKeys returns the keys that are held down, in the order of their presses
+func (d *dispatcher) HeldKeys() []HeldKey {
+	d.kmx.Lock()
+	defer d.kmx.Unlock()
+	held := make([]HeldKey, len(d.keys.held))
+	copy(held, d.keys.held)
+	return held
+}
+
+// IsDown returns wether the key at x,y relative to the area is held down
+func (a *area) IsDown(x, y uint8) bool {
+	_, down := a.PressedAt(x, y)
+	return down
+}
+
+// PressedAt returns when the key at x,y relative to the area has been pressed
+// and false, if it is not held down
+func (a *area) PressedAt(x, y uint8) (time.Time, bool) {
+	if checkRange(a, x, y) != nil {
+		return time.Time{}, false
+	}
+	return a.Connection.PressedAt(a.toConnection(x, y))
+}
+
+// HeldKeys returns the keys of the area that are held down, relative to the area
+func (a *area) HeldKeys() []HeldKey {
+	conv := a.Convention()
+	bounds := Rect{Min: Point{Row: a.x, Col: a.y}, Rows: a.Rows(), Cols: a.Cols()}
+	var held []HeldKey
+	for _, k := range a.Connection.HeldKeys() {
+		p := conv.Point(k.X, k.Y)
+		if !bounds.Contains(p) {
+			continue
+		}
+		k.X, k.Y = conv.XY(p.Sub(bounds.Min))
+		held = append(held, k)
+	}
+	return held
+}
@@ -0,0 +1,214 @@
+package monome
+
+import (
+	"context"
+	"fmt"
+)
+
+// Placement places a connection on the canvas of a LayoutConnection
+type Placement struct {
+	Connection Connection
+
+	// X is the row and Y the column of the top left corner of the
+	// (rotated) connection on the canvas
+	X uint8
+	Y uint8
+
+	// Rotation is the clockwise rotation of the connection on the canvas
+	Rotation Rotation
+}
+
+// size returns the rows and cols the placement covers on the canvas
+func (p *Placement) size() (rows, cols uint8) {
+	return p.Rotation.size(p.Connection.Rows(), p.Connection.Cols())
+}
+
+func (p *Placement) contains(x, y uint8) bool {
+	rows, cols := p.size()
+	return x >= p.X && int(x) < int(p.X)+int(rows) &&
+		y >= p.Y && int(y) < int(p.Y)+int(cols)
+}
+
+// toCanvas maps x,y of the connection to the canvas
+func (p *Placement) toCanvas(x, y uint8) (uint8, uint8) {
+	x, y = p.Rotation.fromPhysical(x, y, p.Connection.Rows(), p.Connection.Cols())
+	return p.X + x, p.Y + y
+}
+
+// fromCanvas maps x,y of the canvas to the connection
+func (p *Placement) fromCanvas(x, y uint8) (uint8, uint8) {
+	return p.Rotation.toPhysical(x-p.X, y-p.Y, p.Connection.Rows(), p.Connection.Cols())
+}
+
+var _ Connection = &layoutConnection{}
+
+type layoutConnection struct {
+	dispatcher
+	lifecycle
+	placements []Placement
+	name       string
+}
+
+// LayoutConnection creates a unified connection out of connections that are placed
+// on a two dimensional canvas, each one with its own offset and rotation.
+// The canvas is big enough to include all connections. Keys of the canvas that are
+// not covered by any connection are gaps: setting them has no effect.
+// If connections overlap, the one that was placed first wins.
+// The layout connection uses the RowCol convention, independent of the
+// conventions of the placed connections.
+// The layout connection is done and closed as soon as one of the placed connections is,
+// since it can't be used without it; Close closes all placed connections.
+func LayoutConnection(name string, placements ...Placement) Connection {
+	m := &layoutConnection{
+		dispatcher: newDispatcher(),
+		placements: placements,
+		name:       name,
+	}
+	if m.name == "" {
+		m.name = "monome layout"
+	}
+	for i := range m.placements {
+		p := &m.placements[i]
+		p.Connection.Subscribe(memberHandler(m, func(pt Point) (uint8, uint8) {
+			return p.toCanvas(pt.Row, pt.Col)
+		}))
+	}
+	watchAll(&m.lifecycle, m.connections())
+	return m
+}
+
+// Rows returns the number of rows of the canvas
+func (m *layoutConnection) Rows() uint8 {
+	var rows int
+	for i := range m.placements {
+		r, _ := m.placements[i].size()
+		if n := int(m.placements[i].X) + int(r); n > rows {
+			rows = n
+		}
+	}
+	if rows > 255 {
+		return 255
+	}
+	return uint8(rows)
+}
+
+// Cols returns the number of cols of the canvas
+func (m *layoutConnection) Cols() uint8 {
+	var cols int
+	for i := range m.placements {
+		_, c := m.placements[i].size()
+		if n := int(m.placements[i].Y) + int(c); n > cols {
+			cols = n
+		}
+	}
+	if cols > 255 {
+		return 255
+	}
+	return uint8(cols)
+}
+
+func (m *layoutConnection) placementAt(x, y uint8) *Placement {
+	for i := range m.placements {
+		if m.placements[i].contains(x, y) {
+			return &m.placements[i]
+		}
+	}
+	return nil
+}
+
+func (m *layoutConnection) Switch(x, y uint8, on bool) error {
+	var brightness uint8
+	if on {
+		brightness = 15
+	}
+	return m.Set(x, y, brightness)
+}
+
+// Set sets the light of the connection that covers x,y.
+// Setting a gap has no effect.
+func (m *layoutConnection) Set(x, y, brightness uint8) error {
+	if err := checkRange(m, x, y); err != nil {
+		return err
+	}
+	p := m.placementAt(x, y)
+	if p == nil {
+		return nil
+	}
+	px, py := p.fromCanvas(x, y)
+	return SetPoint(p.Connection, Point{Row: px, Col: py}, brightness)
+}
+
+func (m *layoutConnection) Events() <-chan Event {
+	return m.eventsOf(m)
+}
+
+func (m *layoutConnection) StartListening(errHandler func(error)) {
+	for _, p := range m.placements {
+		p.Connection.StartListening(errHandler)
+	}
+}
+
+// connections returns the placed connections
+func (m *layoutConnection) connections() []Connection {
+	conns := make([]Connection, len(m.placements))
+	for i, p := range m.placements {
+		conns[i] = p.Connection
+	}
+	return conns
+}
+
+// Listen listens to all connections. If one of them fails, the listening to the others is stopped.
+func (m *layoutConnection) Listen(ctx context.Context) error {
+	defer m.resetKeys()
+	return listenAll(ctx, m.connections())
+}
+
+// StopListening stops listening to all connections and forgets the held keys
+func (m *layoutConnection) StopListening() {
+	defer m.resetKeys()
+	for _, p := range m.placements {
+		p.Connection.StopListening()
+	}
+}
+
+func (m *layoutConnection) String() string {
+	return fmt.Sprintf("%s%d", m.name, int(m.Rows())*int(m.Cols()))
+}
+
+func (m *layoutConnection) ReadMessage() error {
+	panic("don't call me")
+}
+
+// Sync waits for the queued lights of all connections
+func (m *layoutConnection) Sync() error {
+	var errs Errors
+	for _, p := range m.placements {
+		errs.Add(p.Connection.Sync())
+	}
+	if errs.Len() == 0 {
+		return nil
+	}
+	errs.Task = fmt.Sprintf("sync layout device %s", m.String())
+	return &errs
+}
+
+// Close closes all connections
+func (m *layoutConnection) Close() error {
+	var errs Errors
+	for _, p := range m.placements {
+		errs.Add(p.Connection.Close())
+	}
+	m.finish(ConnectionClosedError(m.String()))
+	m.closeEvents(m, nil)
+
+	if errs.Len() == 0 {
+		return nil
+	}
+	errs.Task = fmt.Sprintf("close layout device %s", m.String())
+	return &errs
+}
+
+// IsClosed returns true, as soon as one of the connections is closed, like Done
+func (m *layoutConnection) IsClosed() bool {
+	return anyClosed(&m.lifecycle, m.connections())
+}
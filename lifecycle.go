@@ -0,0 +1,113 @@
+package monome
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle implements Done and Err of a Connection
+type lifecycle struct {
+	lmx      sync.Mutex
+	done     chan struct{}
+	doneOnce sync.Once
+	err      error
+}
+
+func (l *lifecycle) doneChan() chan struct{} {
+	l.doneOnce.Do(func() {
+		l.done = make(chan struct{})
+	})
+	return l.done
+}
+
+// Done returns a channel that is closed when the connection is closed,
+// either by calling Close or because of an error
+func (l *lifecycle) Done() <-chan struct{} {
+	return l.doneChan()
+}
+
+// Err returns nil, as long as Done is not closed. Afterwards it returns
+// the error that ended the connection or a ConnectionClosedError, if it was closed by Close.
+func (l *lifecycle) Err() error {
+	l.lmx.Lock()
+	defer l.lmx.Unlock()
+	return l.err
+}
+
+// ended returns wether Done is closed
+func (l *lifecycle) ended() bool {
+	select {
+	case <-l.doneChan():
+		return true
+	default:
+		return false
+	}
+}
+
+// finish ends the lifecycle with the given error (ErrClosed if nil).
+// Only the first call has an effect; it returns true for the first call.
+func (l *lifecycle) finish(err error) bool {
+	if err == nil {
+		err = ErrClosed
+	}
+	done := l.doneChan()
+	l.lmx.Lock()
+	defer l.lmx.Unlock()
+	if l.err != nil {
+		return false
+	}
+	l.err = err
+	close(done)
+	return true
+}
+
+// anyClosed returns wether l has ended or one of the given connections is closed,
+// which is what ends l by watchAll
+func anyClosed(l *lifecycle, conns []Connection) bool {
+	if l.ended() {
+		return true
+	}
+	for _, c := range conns {
+		if c.IsClosed() {
+			return true
+		}
+	}
+	return false
+}
+
+// listenAll listens to all given connections, until ctx is done or all of them have stopped listening.
+// The first error of a connection stops the listening to the others and is returned.
+func listenAll(ctx context.Context, conns []Connection) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(conns))
+	for _, c := range conns {
+		go func(c Connection) {
+			errs <- c.Listen(ctx)
+		}(c)
+	}
+
+	var first error
+	for range conns {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
+
+// watchAll finishes l, as soon as one of the given connections is done.
+// IsClosed of composite connections must follow the same rule, see anyClosed.
+func watchAll(l *lifecycle, conns []Connection) {
+	for _, c := range conns {
+		go func(c Connection) {
+			select {
+			case <-c.Done():
+				l.finish(c.Err())
+			case <-l.Done():
+			}
+		}(c)
+	}
+}
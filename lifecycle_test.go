@@ -0,0 +1,47 @@
+package monome
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompositeClosedWithMember(t *testing.T) {
+	tests := []struct {
+		name      string
+		composite func(a, b *Virtual) Connection
+	}{
+		{"row", func(a, b *Virtual) Connection {
+			return RowConnection("row", a, b)
+		}},
+		{"layout", func(a, b *Virtual) Connection {
+			return LayoutConnection("layout", Placement{Connection: a}, Placement{Connection: b, Y: 8})
+		}},
+		{"region", func(a, b *Virtual) Connection {
+			return Split(a).Region("region", 0, 0, 4, 4)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := NewVirtual(8, 8), NewVirtual(8, 8)
+			defer b.Close()
+			c := test.composite(a, b)
+			if c.IsClosed() {
+				t.Fatal("IsClosed() = true before any member is closed")
+			}
+
+			a.Close()
+			if !c.IsClosed() {
+				t.Error("IsClosed() = false after a member is closed")
+			}
+			select {
+			case <-c.Done():
+			case <-time.After(time.Second):
+				t.Fatal("Done() not closed after a member is closed")
+			}
+			if c.Err() == nil {
+				t.Error("Err() = nil after Done() is closed")
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package monome
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger sets the handler for the log messages of the connection.
+// The messages carry the device and, where it applies, the coordinates and the error
+// as structured fields. By default nothing is logged.
+func Logger(h slog.Handler) Option {
+	return func(m *connection) {
+		m.logger = newLogger(h)
+	}
+}
+
+// newLogger returns a logger for the given handler. For a nil handler, nothing is logged.
+func newLogger(h slog.Handler) *slog.Logger {
+	if h == nil {
+		h = discardHandler{}
+	}
+	return slog.New(h)
+}
+
+// discardHandler is a slog.Handler that discards everything
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
@@ -2,7 +2,11 @@ package monome
 
 import "fmt"
 
-type m128 struct{ mn monomeConnection }
+type m128 struct {
+	mn   monomeConnection
+	buf  []byte
+	rest []byte
+}
 
 var _ Device = &m128{}
 
@@ -19,16 +23,16 @@ func (m *m128) Switch(x, y uint8, on bool) error {
 		return nil
 	}
 
-	e := err.(Error)
 	if on {
-		e.Task = "switch on"
-	} else {
-		e.Task = "switch off"
+		return withTask(err, "switch on")
 	}
-	return e
+	return withTask(err, "switch off")
 }
 
 func (m *m128) Set(x, y, brightness uint8) error {
+	if err := checkRange(m, x, y); err != nil {
+		return err
+	}
 	if brightness > 15 {
 		brightness = 15
 	}
@@ -46,7 +50,7 @@ func (m *m128) Set(x, y, brightness uint8) error {
 }
 
 func (m *m128) ReadMessage() error {
-	var b = make([]byte, int(m.mn.maxPacketSizeRead()))
+	b := readBuffer(&m.buf, m.mn.maxPacketSizeRead())
 	got, err := m.mn.Read(b)
 
 	if err != nil {
@@ -56,14 +60,22 @@ func (m *m128) ReadMessage() error {
 		}
 	}
 
-	if got > 2 {
-		for i := 2; i < got-2; i += 3 {
-			if b[i] == 0 {
-				continue
-			}
-			x, y := b[i+2], b[i+1]
-			m.mn.Handle(m.mn, x, y, b[i] == 0x21 /* down */)
+	if got <= 2 {
+		return nil
+	}
+
+	// a message might be split between reads, the rest is kept for the next read
+	data := append(m.rest, b[2:got]...)
+	for len(data) >= 3 {
+		if data[0] != 0x20 && data[0] != 0x21 {
+			// not the start of a key message, e.g. because of a lost byte
+			data = data[1:]
+			continue
 		}
+		x, y := data[2], data[1]
+		m.mn.Handle(m.mn.conn(), x, y, data[0] == 0x21 /* down */)
+		data = data[3:]
 	}
+	m.rest = append(m.rest[:0], data...)
 	return nil
 }
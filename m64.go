@@ -6,31 +6,38 @@ import (
 
 var _ Device = &m64{}
 
-type m64 struct{ mn monomeConnection }
+type m64 struct {
+	mn   monomeConnection
+	buf  []byte
+	rest []byte
+}
 
 func (m *m64) String() string { return "monome64" }
 func (m *m64) Rows() uint8    { return 8 }
 func (m *m64) Cols() uint8    { return 8 }
 func (m *m64) Switch(x, y uint8, on bool) error {
-	y = changeY(y)
+	if err := checkRange(m, x, y); err != nil {
+		return err
+	}
 	var first byte = 0x30
 	if on {
 		first = 0x21
 	}
-	_, err := m.mn.Write([]byte{first, (x << 4) | y})
-	if err != nil {
-		var e Error
-		e.Device = m.String()
-		e.X = x
-		e.Y = y
-		e.WrappedError = err
-		if on {
-			e.Task = "switch on"
-		} else {
-			e.Task = "switch off"
-		}
+	_, err := m.mn.Write([]byte{first, (x << 4) | changeY(y)})
+	if err == nil {
+		return nil
 	}
-	return nil
+	var e Error
+	e.Device = m.String()
+	e.X = x
+	e.Y = y
+	e.WrappedError = err
+	if on {
+		e.Task = "switch on"
+	} else {
+		e.Task = "switch off"
+	}
+	return e
 }
 
 func (m *m64) Set(x, y, brightness uint8) error {
@@ -38,28 +45,36 @@ func (m *m64) Set(x, y, brightness uint8) error {
 	if err == nil {
 		return nil
 	}
-	e := err.(Error)
-	e.Task = fmt.Sprintf("set brightness to %d", brightness)
-	return e
+	return withTask(err, fmt.Sprintf("set brightness to %d", brightness))
 }
 
 func (m *m64) ReadMessage() error {
-	var b = make([]byte, m.mn.maxPacketSizeRead())
+	b := readBuffer(&m.buf, m.mn.maxPacketSizeRead())
 	got, err := m.mn.Read(b)
 
 	if err != nil {
 		return ReadError{Device: m.String(), WrappedError: err}
 	}
 
-	if got > 2 {
-		for i := 2; i < got; i += 2 {
-			// fmt.Printf("b[i] % X  b[i+1] % X\n", b[i], b[i+1])
-			x, y := b[i+1]/16, b[i+1]%16
-			y = changeY(y)
-			m.mn.Handle(m.mn, x, y, b[i] == 0 /* down */)
-		}
+	if got <= 2 {
+		return nil
 	}
 
+	// a message might be split between reads, the rest is kept for the next read
+	data := append(m.rest, b[2:got]...)
+	for len(data) >= 2 {
+		if data[0] != 0x00 && data[0] != 0x10 {
+			// not the start of a key message, e.g. because of a lost byte
+			data = data[1:]
+			continue
+		}
+		// fmt.Printf("data[0] % X  data[1] % X\n", data[0], data[1])
+		x, y := data[1]/16, data[1]%16
+		y = changeY(y)
+		m.mn.Handle(m.mn.conn(), x, y, data[0] == 0 /* down */)
+		data = data[2:]
+	}
+	m.rest = append(m.rest[:0], data...)
 	return nil
 }
 
@@ -0,0 +1,326 @@
+package monome
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/karalabe/gousb/usb"
+)
+
+// DefaultScanInterval is the default interval in which a Manager looks for devices
+const DefaultScanInterval = time.Second
+
+// Manager watches for monome devices being plugged in and out.
+//
+// New devices are connected with the options of the manager and announced with a
+// DeviceAddedEvent. Devices that disappear or fail are closed and announced with a
+// DeviceRemovedEvent. Errors while looking for devices are announced with an ErrorEvent.
+// The events are passed to the event handler and the subscriptions, together with the
+// connection they are about, and to the channel returned by Events. ErrorEvents are not about
+// a connection, so handlers get them with a nil Connection.
+//
+// The devices are found by polling the USB bus and are told apart by their serial number,
+// so that a device is only connected once. Connections with the AutoReconnect option are
+// kept while their device is unplugged, but Connections and Get leave them out until
+// the device is back. Rescan triggers an immediate scan, e.g. when a udev rule or another
+// hotplug notification reports a change.
+type Manager struct {
+	handlers dispatcher
+	options  []Option
+	interval time.Duration
+	mx       sync.Mutex
+	conns    map[string]Connection
+	rescan   chan struct{}
+}
+
+// reconnecter is implemented by connections that might reconnect (see AutoReconnect)
+type reconnecter interface {
+	reconnects() bool
+	isReconnecting() bool
+}
+
+// plugged returns wether the device of c is plugged in, i.e. it is not waiting to be reconnected
+func plugged(c Connection) bool {
+	r, ok := c.(reconnecter)
+	return !ok || !r.isReconnecting()
+}
+
+// candidate is a device found by a scan, identified by key
+type candidate struct {
+	key     string
+	connect func() (Connection, error)
+	release func() error
+}
+
+// NewManager returns a Manager that looks for devices in the given interval
+// (DefaultScanInterval if it is 0) and connects to them with the given options.
+func NewManager(interval time.Duration, options ...Option) *Manager {
+	if interval <= 0 {
+		interval = DefaultScanInterval
+	}
+	return &Manager{
+		handlers: newDispatcher(),
+		options:  options,
+		interval: interval,
+		conns:    map[string]Connection{},
+		rescan:   make(chan struct{}, 1),
+	}
+}
+
+// Events returns a channel that receives the events of the manager.
+// The channel is created on the first call and closed when Run returns.
+func (m *Manager) Events() <-chan Event {
+	return m.handlers.events.channel()
+}
+
+// SetEventHandler sets the handler for the events of the manager.
+// The Connection passed with an ErrorEvent is nil.
+func (m *Manager) SetEventHandler(h EventHandler) {
+	m.handlers.SetEventHandler(h)
+}
+
+// Subscribe adds a handler for the events of the manager, see Connection.Subscribe.
+// The Connection passed with an ErrorEvent is nil.
+func (m *Manager) Subscribe(h EventHandler, options ...SubscribeOption) (unsubscribe func()) {
+	return m.handlers.Subscribe(h, options...)
+}
+
+// Connections returns the connections to the devices that are currently plugged in
+func (m *Manager) Connections() []Connection {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	conns := make([]Connection, 0, len(m.conns))
+	for _, c := range m.conns {
+		if plugged(c) {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// Get returns the connection to the device with the given id or nil, if it is not plugged in
+func (m *Manager) Get(id DeviceID) Connection {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for _, c := range m.conns {
+		if IDOf(c) == id && plugged(c) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Rescan triggers a scan for devices, without waiting for the next interval
+func (m *Manager) Rescan() {
+	select {
+	case m.rescan <- struct{}{}:
+	default:
+	}
+}
+
+// Run watches for devices until the given context is done. Then all connections are closed,
+// the event channel is closed and the error of the context is returned.
+// Run should only be called once.
+func (m *Manager) Run(ctx context.Context) error {
+	usbCtx, err := usb.NewContext()
+	if err != nil {
+		return USBAccessError
+	}
+	defer usbCtx.Close()
+
+	keys := usbKeys{}
+	return m.run(ctx, func() ([]candidate, error) {
+		return usbCandidates(usbCtx, keys, m.options)
+	})
+}
+
+func (m *Manager) run(ctx context.Context, scan func() ([]candidate, error)) error {
+	defer m.handlers.events.close(nil)
+	defer m.closeAll()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	m.update(ctx, scan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			m.update(ctx, scan)
+		case <-m.rescan:
+			m.update(ctx, scan)
+		}
+	}
+}
+
+// update connects to new devices and removes the devices that are gone
+func (m *Manager) update(ctx context.Context, scan func() ([]candidate, error)) {
+	found, err := scan()
+	if err != nil {
+		m.handlers.dispatch(nil, ErrorEvent{EventHeader: EventHeader{Device: DeviceID{Name: "monome manager"}, At: time.Now()}, Err: err})
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, cand := range found {
+		seen[cand.key] = true
+		m.mx.Lock()
+		_, known := m.conns[cand.key]
+		m.mx.Unlock()
+		if known || ctx.Err() != nil {
+			cand.release()
+			continue
+		}
+		c, err := cand.connect()
+		if err != nil {
+			m.handlers.dispatch(nil, ErrorEvent{EventHeader: EventHeader{Device: DeviceID{Name: cand.key}, At: time.Now()}, Err: err})
+			continue
+		}
+		m.add(cand.key, c)
+	}
+
+	m.mx.Lock()
+	var gone []string
+	for key, c := range m.conns {
+		if r, ok := c.(reconnecter); ok && r.reconnects() {
+			// it will be back or closed
+			continue
+		}
+		if !seen[key] {
+			gone = append(gone, key)
+		}
+	}
+	m.mx.Unlock()
+
+	for _, key := range gone {
+		m.remove(key, nil, ErrDisconnected)
+	}
+}
+
+func (m *Manager) add(key string, c Connection) {
+	m.mx.Lock()
+	m.conns[key] = c
+	m.mx.Unlock()
+
+	m.handlers.dispatch(c, DeviceAddedEvent{EventHeader: header(c)})
+
+	go func() {
+		<-c.Done()
+		m.remove(key, c, c.Err())
+	}()
+}
+
+// remove closes and removes the connection for the given key. If c is not nil, it is only removed,
+// if it is still the connection for the key.
+func (m *Manager) remove(key string, c Connection, err error) {
+	m.mx.Lock()
+	current, has := m.conns[key]
+	if !has || (c != nil && current != c) {
+		m.mx.Unlock()
+		return
+	}
+	delete(m.conns, key)
+	m.mx.Unlock()
+
+	current.Close()
+	m.handlers.dispatch(current, DeviceRemovedEvent{EventHeader: header(current), Err: err})
+}
+
+func (m *Manager) closeAll() {
+	m.mx.Lock()
+	var keys []string
+	for key := range m.conns {
+		keys = append(keys, key)
+	}
+	m.mx.Unlock()
+
+	for _, key := range keys {
+		m.remove(key, nil, nil)
+	}
+}
+
+// usbCandidates returns the monome devices on the USB bus
+func usbCandidates(ctx *usb.Context, keys usbKeys, options []Option) ([]candidate, error) {
+	devs, err := ctx.ListDevices(isMonome)
+	if err != nil {
+		return nil, wrapUSBError(err)
+	}
+	keys.keep(devs)
+
+	cands := make([]candidate, len(devs))
+	for i, dev := range devs {
+		dev := dev
+		cands[i] = candidate{
+			key:     keys.key(dev),
+			release: dev.Close,
+			connect: func() (Connection, error) {
+				c, err := Connect(dev, options...)
+				if err != nil {
+					dev.Close()
+					return nil, err
+				}
+				return c, nil
+			},
+		}
+	}
+	return cands, nil
+}
+
+const (
+	usbRequestIn        = 0x80
+	usbGetDescriptor    = 0x06
+	usbDeviceDescriptor = 0x01
+
+	// usbSerialNumberIndex is the offset of the index of the serial number in the device descriptor
+	usbSerialNumberIndex = 16
+)
+
+// usbSerial returns the serial number of the given device or an empty string, if it is unknown
+func usbSerial(dev *usb.Device) string {
+	// the index of the string descriptor of the serial number is only part of the raw device descriptor
+	var desc [18]byte
+	n, err := dev.Control(usbRequestIn, usbGetDescriptor, usbDeviceDescriptor<<8, 0, desc[:])
+	if err != nil || n <= usbSerialNumberIndex || desc[usbSerialNumberIndex] == 0 {
+		return ""
+	}
+	serial, err := dev.GetStringDescriptor(int(desc[usbSerialNumberIndex]))
+	if err != nil {
+		return ""
+	}
+	return serial
+}
+
+// usbKeys caches the keys of the devices by their place on the bus,
+// so that the serial numbers of known devices are not queried on every scan
+type usbKeys map[[2]uint8]string
+
+// key identifies the given device by its serial number, falling back to its place on the bus
+func (k usbKeys) key(dev *usb.Device) string {
+	place := [2]uint8{dev.Descriptor.Bus, dev.Descriptor.Address}
+	if key, has := k[place]; has {
+		return key
+	}
+	key := usbSerial(dev)
+	if key == "" {
+		key = fmt.Sprintf("bus %d address %d", place[0], place[1])
+	}
+	k[place] = key
+	return key
+}
+
+// keep forgets the keys of the devices that are not in devs, since their place might be reused
+func (k usbKeys) keep(devs []*usb.Device) {
+	found := map[[2]uint8]bool{}
+	for _, dev := range devs {
+		found[[2]uint8{dev.Descriptor.Bus, dev.Descriptor.Address}] = true
+	}
+	for place := range k {
+		if !found[place] {
+			delete(k, place)
+		}
+	}
+}
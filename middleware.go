@@ -0,0 +1,301 @@
+package monome
+
+import (
+	"sync"
+	"time"
+)
+
+// The middlewares wrap a Handler and return a new one, so they can be chained, e.g.
+//
+//	conn.SetHandler(Debounce(5*time.Millisecond, Remap(table, app)))
+//
+// They keep their state per device (see IDOf) and key, so a middleware may be used for several
+// connections. The handler might be called from a timer goroutine (Debounce and Repeat).
+// The middlewares are Resetters: the state of a connection is discarded, when the listening
+// stops or the device is removed, and the reset is passed on to the wrapped handler.
+
+// key identifies a key of a device
+type key struct {
+	id   DeviceID
+	x, y uint8
+}
+
+func keyOf(c Connection, x, y uint8) key {
+	return key{IDOf(c), x, y}
+}
+
+// middleware is a Handler that resets its state and then the wrapped handler
+type middleware struct {
+	handle func(c Connection, x, y uint8, down bool)
+	reset  func(id DeviceID)
+	next   Handler
+}
+
+func (m *middleware) Handle(c Connection, x, y uint8, down bool) {
+	m.handle(c, x, y, down)
+}
+
+func (m *middleware) Reset(c Connection) {
+	if m.reset != nil {
+		m.reset(IDOf(c))
+	}
+	if r, ok := m.next.(Resetter); ok {
+		r.Reset(c)
+	}
+}
+
+// Debounce suppresses the bouncing of the key contacts: after a key changed its state,
+// further changes within the window are ignored. If the key ends up in a different state
+// than reported, that state is reported when the window is over.
+func Debounce(window time.Duration, h Handler) Handler {
+	d := &debouncer{window: window, h: h, keys: map[key]*debounceState{}}
+	return &middleware{handle: d.handle, reset: d.reset, next: h}
+}
+
+type debounceState struct {
+	c        Connection
+	reported bool
+	actual   bool
+	until    time.Time
+	timer    *time.Timer
+}
+
+type debouncer struct {
+	window time.Duration
+	h      Handler
+	mx     sync.Mutex
+	keys   map[key]*debounceState
+}
+
+func (d *debouncer) handle(c Connection, x, y uint8, down bool) {
+	k := keyOf(c, x, y)
+	d.mx.Lock()
+	st, has := d.keys[k]
+	if !has {
+		st = &debounceState{}
+		d.keys[k] = st
+	}
+	st.c = c
+	st.actual = down
+	now := time.Now()
+	if now.Before(st.until) {
+		if st.timer == nil {
+			st.timer = time.AfterFunc(st.until.Sub(now), func() { d.settle(k, st) })
+		}
+		d.mx.Unlock()
+		return
+	}
+	report := st.reported != down
+	if report {
+		st.reported = down
+		st.until = now.Add(d.window)
+	}
+	d.mx.Unlock()
+
+	if report {
+		d.h.Handle(c, x, y, down)
+	}
+}
+
+// settle reports the state of the key at the end of the window, if it has changed
+func (d *debouncer) settle(k key, st *debounceState) {
+	d.mx.Lock()
+	if d.keys[k] != st {
+		// reset in the meantime
+		d.mx.Unlock()
+		return
+	}
+	st.timer = nil
+	report := st.reported != st.actual
+	if report {
+		st.reported = st.actual
+		st.until = time.Now().Add(d.window)
+	} else if !st.actual {
+		// forget released keys
+		delete(d.keys, k)
+	}
+	down, c := st.reported, st.c
+	d.mx.Unlock()
+
+	if report {
+		d.h.Handle(c, k.x, k.y, down)
+	}
+}
+
+// reset forgets the keys of the device
+func (d *debouncer) reset(id DeviceID) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for k, st := range d.keys {
+		if k.id != id {
+			continue
+		}
+		if st.timer != nil {
+			st.timer.Stop()
+		}
+		delete(d.keys, k)
+	}
+}
+
+// Repeat repeats the key down events for held keys: the first repetition is
+// after the given delay, the following ones in the given interval, until the key is released.
+func Repeat(delay, interval time.Duration, h Handler) Handler {
+	r := &repeater{delay: delay, interval: interval, h: h, keys: map[key]*repeatState{}}
+	return &middleware{handle: r.handle, reset: r.reset, next: h}
+}
+
+type repeater struct {
+	delay    time.Duration
+	interval time.Duration
+	h        Handler
+	mx       sync.Mutex
+	keys     map[key]*repeatState
+}
+
+// repeatState is a held key. Its events are passed to the handler with deliver locked,
+// so that a repetition can't overtake the release.
+type repeatState struct {
+	timer   *time.Timer
+	deliver sync.Mutex
+}
+
+func (r *repeater) handle(c Connection, x, y uint8, down bool) {
+	k := keyOf(c, x, y)
+	r.mx.Lock()
+	st, held := r.keys[k]
+	if held {
+		st.timer.Stop()
+		delete(r.keys, k)
+	}
+	if down {
+		st = &repeatState{}
+		st.deliver.Lock()
+		st.timer = time.AfterFunc(r.delay, func() { r.repeat(c, x, y, k, st) })
+		r.keys[k] = st
+		r.mx.Unlock()
+
+		r.h.Handle(c, x, y, true)
+		st.deliver.Unlock()
+		return
+	}
+	r.mx.Unlock()
+
+	if held {
+		// wait for a running repetition
+		st.deliver.Lock()
+		defer st.deliver.Unlock()
+	}
+	r.h.Handle(c, x, y, false)
+}
+
+// repeat passes a repetition of the key, if it is still held
+func (r *repeater) repeat(c Connection, x, y uint8, k key, st *repeatState) {
+	st.deliver.Lock()
+	defer st.deliver.Unlock()
+
+	r.mx.Lock()
+	held := r.keys[k] == st
+	if held {
+		st.timer.Reset(r.interval)
+	}
+	r.mx.Unlock()
+
+	if held {
+		r.h.Handle(c, x, y, true)
+	}
+}
+
+// reset stops the repetitions of the keys of the device
+func (r *repeater) reset(id DeviceID) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for k, st := range r.keys {
+		if k.id == id {
+			st.timer.Stop()
+			delete(r.keys, k)
+		}
+	}
+}
+
+// Remap maps the keys to other keys, based on the given table, e.g. to fix the wiring of a DIY grid.
+// The keys that are not in the table are passed unchanged. The table is independent of the
+// convention of the connection (see Point). Only the key events are remapped, not the lights.
+func Remap(table map[Point]Point, h Handler) Handler {
+	return &middleware{next: h, handle: func(c Connection, x, y uint8, down bool) {
+		conv := ConventionOf(c)
+		if to, has := table[conv.Point(x, y)]; has {
+			x, y = conv.XY(to)
+		}
+		h.Handle(c, x, y, down)
+	}}
+}
+
+// Mask swallows the key events inside of the given rectangles and passes the others to h
+func Mask(h Handler, masked ...Rect) Handler {
+	return &middleware{next: h, handle: func(c Connection, x, y uint8, down bool) {
+		p := ConventionOf(c).Point(x, y)
+		for _, r := range masked {
+			if r.Contains(p) {
+				return
+			}
+		}
+		h.Handle(c, x, y, down)
+	}}
+}
+
+// Throttle passes at most one press of a key within the given interval. The releases of the
+// presses that have been dropped are dropped too.
+func Throttle(interval time.Duration, h Handler) Handler {
+	t := &throttler{interval: interval, h: h, keys: map[key]*throttleState{}}
+	return &middleware{handle: t.handle, reset: t.reset, next: h}
+}
+
+type throttleState struct {
+	last    time.Time
+	dropped bool
+}
+
+type throttler struct {
+	interval time.Duration
+	h        Handler
+	mx       sync.Mutex
+	keys     map[key]*throttleState
+}
+
+func (t *throttler) handle(c Connection, x, y uint8, down bool) {
+	k := keyOf(c, x, y)
+	t.mx.Lock()
+	st, has := t.keys[k]
+	if !has {
+		st = &throttleState{}
+		t.keys[k] = st
+	}
+	var pass bool
+	if down {
+		now := time.Now()
+		pass = now.Sub(st.last) >= t.interval
+		if pass {
+			st.last = now
+		}
+		st.dropped = !pass
+	} else {
+		pass = !st.dropped
+		st.dropped = false
+	}
+	t.mx.Unlock()
+
+	if pass {
+		t.h.Handle(c, x, y, down)
+	}
+}
+
+// reset forgets the keys of the device
+func (t *throttler) reset(id DeviceID) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for k := range t.keys {
+		if k.id == id {
+			delete(t.keys, k)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package monome
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// keyRecorder records the key events it handles
+type keyRecorder struct {
+	mx   sync.Mutex
+	keys []bool
+}
+
+func (k *keyRecorder) Handle(c Connection, x, y uint8, down bool) {
+	k.mx.Lock()
+	k.keys = append(k.keys, down)
+	k.mx.Unlock()
+}
+
+func (k *keyRecorder) get() []bool {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	return append([]bool(nil), k.keys...)
+}
+
+func TestRepeatBehindMux(t *testing.T) {
+	v := NewVirtual(8, 8, ReadTimeout(5*time.Millisecond))
+	defer v.Close()
+
+	var rec keyRecorder
+	mux := NewMux()
+	mux.HandleRect(0, 0, 4, 4, Repeat(10*time.Millisecond, 10*time.Millisecond, &rec))
+	v.SetHandler(mux)
+	v.StartListening(nil)
+
+	v.Tap(1, 1)
+	if err := v.Settle(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if got := rec.get(); len(got) != 2 || !got[0] || got[1] {
+		t.Errorf("keys after a tap = %v, expected [true false]", got)
+	}
+}
+
+func TestRepeatReleaseIsLast(t *testing.T) {
+	v := NewVirtual(8, 8, ReadTimeout(5*time.Millisecond))
+	defer v.Close()
+
+	var rec keyRecorder
+	slow := HandlerFunc(func(c Connection, x, y uint8, down bool) {
+		rec.Handle(c, x, y, down)
+		// give a release the chance to overtake the repetition
+		time.Sleep(time.Millisecond)
+	})
+	v.SetHandler(Repeat(time.Millisecond, time.Millisecond, slow))
+	v.StartListening(nil)
+
+	for i := 0; i < 20; i++ {
+		v.Sequence(KeyStep{X: 2, Y: 2, Down: true}, KeyStep{X: 2, Y: 2, Wait: 3 * time.Millisecond})
+	}
+	if err := v.Settle(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	got := rec.get()
+	if len(got) == 0 || got[len(got)-1] {
+		t.Fatalf("a repetition came after the last release: %v", got)
+	}
+	var ups int
+	for _, down := range got {
+		if !down {
+			ups++
+		}
+	}
+	if ups != 20 {
+		t.Errorf("%d releases, expected 20", ups)
+	}
+}
+
+func TestRepeatStopsWithListening(t *testing.T) {
+	v := NewVirtual(8, 8, ReadTimeout(5*time.Millisecond))
+	defer v.Close()
+
+	var rec keyRecorder
+	v.SetHandler(Repeat(5*time.Millisecond, 5*time.Millisecond, &rec))
+	v.StartListening(nil)
+	v.Press(3, 3)
+	if err := v.Settle(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	v.StopListening()
+
+	n := len(rec.get())
+	time.Sleep(30 * time.Millisecond)
+	if got := len(rec.get()); got != n {
+		t.Errorf("%d repetitions after StopListening", got-n)
+	}
+}
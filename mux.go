@@ -0,0 +1,236 @@
+package monome
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	_ Handler  = &Mux{}
+	_ Resetter = &Mux{}
+)
+
+// Mux is a Handler that dispatches key events to the handlers that
+// are registered for regions of the grid, similar to http.ServeMux.
+//
+// The coordinates that are passed to a registered handler are relative to the
+// top left corner of its region and the passed Connection is a view on that region:
+// its Rows and Cols are the size of the region and Set and Switch are translated
+// and clipped to the region. The regions are always defined by row (x) and column (y),
+// while the coordinates passed to the handlers follow the Convention of the connection.
+//
+// If regions overlap, the handler with the higher priority gets the event.
+// Handlers of the same priority are tried in the order they were registered.
+// A handler that was registered with FallThrough passes the event on
+// to the next matching handler after it has handled it.
+type Mux struct {
+	mx     sync.RWMutex
+	routes []*route
+
+	// NotFound handles keys that are not inside of any region (optional).
+	// It gets the unmodified coordinates and connection.
+	NotFound Handler
+}
+
+// NewMux returns a new Mux without any registered handlers
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// allRows and allCols extend a region to the end of the grid
+const (
+	allRows uint8 = 255
+	allCols uint8 = 255
+)
+
+type route struct {
+	x           uint8
+	y           uint8
+	rows        uint8
+	cols        uint8
+	h           Handler
+	priority    int
+	fallThrough bool
+
+	amx   sync.Mutex
+	areas map[Connection]*area
+}
+
+// area returns the view of the route on c. It is the same for every event of c,
+// so that handlers can keep state per connection.
+func (r *route) area(c Connection) *area {
+	r.amx.Lock()
+	defer r.amx.Unlock()
+	a, has := r.areas[c]
+	if !has {
+		if r.areas == nil {
+			r.areas = map[Connection]*area{}
+		}
+		a = &area{Connection: c, x: r.x, y: r.y, rows: r.rows, cols: r.cols}
+		r.areas[c] = a
+	}
+	return a
+}
+
+func (r *route) rect() Rect {
+	return Rect{Min: Point{Row: r.x, Col: r.y}, Rows: r.rows, Cols: r.cols}
+}
+
+// RouteOption is an option for registering a handler with a Mux
+type RouteOption func(*route)
+
+// Priority sets the priority of the handler. Higher priorities are tried first.
+// The default priority is 0.
+func Priority(p int) RouteOption {
+	return func(r *route) {
+		r.priority = p
+	}
+}
+
+// FallThrough lets the event be passed to the next matching handler,
+// after the handler has been called.
+func FallThrough() RouteOption {
+	return func(r *route) {
+		r.fallThrough = true
+	}
+}
+
+// HandleRect registers h for the rectangle starting at row x and column y,
+// spanning the given number of rows and cols.
+func (m *Mux) HandleRect(x, y, rows, cols uint8, h Handler, options ...RouteOption) {
+	r := &route{x: x, y: y, rows: rows, cols: cols, h: h}
+	for _, opt := range options {
+		opt(r)
+	}
+	m.mx.Lock()
+	m.routes = append(m.routes, r)
+	sort.SliceStable(m.routes, func(a, b int) bool {
+		return m.routes[a].priority > m.routes[b].priority
+	})
+	m.mx.Unlock()
+}
+
+// HandleRegion registers h for the given rectangle
+func (m *Mux) HandleRegion(r Rect, h Handler, options ...RouteOption) {
+	m.HandleRect(r.Min.Row, r.Min.Col, r.Rows, r.Cols, h, options...)
+}
+
+// HandleRow registers h for the whole row x
+func (m *Mux) HandleRow(x uint8, h Handler, options ...RouteOption) {
+	m.HandleRect(x, 0, 1, allCols, h, options...)
+}
+
+// HandleCol registers h for the whole column y
+func (m *Mux) HandleCol(y uint8, h Handler, options ...RouteOption) {
+	m.HandleRect(0, y, allRows, 1, h, options...)
+}
+
+// HandleKey registers h for the single key at x,y
+func (m *Mux) HandleKey(x, y uint8, h Handler, options ...RouteOption) {
+	m.HandleRect(x, y, 1, 1, h, options...)
+}
+
+// Handle dispatches the key event to the matching handlers
+func (m *Mux) Handle(c Connection, x, y uint8, down bool) {
+	conv := ConventionOf(c)
+	p := conv.Point(x, y)
+
+	m.mx.RLock()
+	routes := make([]*route, 0, len(m.routes))
+	for _, r := range m.routes {
+		if r.rect().Contains(p) {
+			routes = append(routes, r)
+		}
+	}
+	m.mx.RUnlock()
+
+	for _, r := range routes {
+		rx, ry := conv.XY(p.Sub(r.rect().Min))
+		r.h.Handle(r.area(c), rx, ry, down)
+		if !r.fallThrough {
+			return
+		}
+	}
+
+	if len(routes) == 0 && m.NotFound != nil {
+		m.NotFound.Handle(c, x, y, down)
+	}
+}
+
+// Reset passes the reset on to the registered handlers and NotFound, if they are Resetters
+func (m *Mux) Reset(c Connection) {
+	m.mx.RLock()
+	routes := append([]*route(nil), m.routes...)
+	m.mx.RUnlock()
+
+	for _, r := range routes {
+		if rs, ok := r.h.(Resetter); ok {
+			rs.Reset(r.area(c))
+		}
+	}
+	if rs, ok := m.NotFound.(Resetter); ok {
+		rs.Reset(c)
+	}
+}
+
+// area is a view on a rectangle of a Connection, starting at row x and column y.
+// All methods except Rows, Cols, Set and Switch act on the underlying connection.
+type area struct {
+	Connection
+	x    uint8
+	y    uint8
+	rows uint8
+	cols uint8
+}
+
+// Rows returns the number of rows of the area that are inside the connection
+func (a *area) Rows() uint8 {
+	return clip(a.x, a.rows, a.Connection.Rows())
+}
+
+// Cols returns the number of cols of the area that are inside the connection
+func (a *area) Cols() uint8 {
+	return clip(a.y, a.cols, a.Connection.Cols())
+}
+
+// Convention returns the convention of the underlying connection
+func (a *area) Convention() Convention {
+	return ConventionOf(a.Connection)
+}
+
+// toConnection maps x,y relative to the area to the underlying connection
+func (a *area) toConnection(x, y uint8) (uint8, uint8) {
+	conv := a.Convention()
+	return conv.XY(conv.Point(x, y).Add(Point{Row: a.x, Col: a.y}))
+}
+
+// Set sets the brightness of x,y relative to the area.
+// Keys outside of the area return an OutOfRangeError.
+func (a *area) Set(x, y, brightness uint8) error {
+	if err := checkRange(a, x, y); err != nil {
+		return err
+	}
+	x, y = a.toConnection(x, y)
+	return a.Connection.Set(x, y, brightness)
+}
+
+// Switch switches the light at x,y relative to the area.
+// Keys outside of the area return an OutOfRangeError.
+func (a *area) Switch(x, y uint8, on bool) error {
+	if err := checkRange(a, x, y); err != nil {
+		return err
+	}
+	x, y = a.toConnection(x, y)
+	return a.Connection.Switch(x, y, on)
+}
+
+// clip returns the part of length, starting at start, that fits into max
+func clip(start, length, max uint8) uint8 {
+	if start >= max {
+		return 0
+	}
+	if int(start)+int(length) > int(max) {
+		return max - start
+	}
+	return length
+}
@@ -0,0 +1,31 @@
+package monome
+
+import "testing"
+
+func TestMuxPassesTheSameArea(t *testing.T) {
+	a, b := NewVirtual(8, 8), NewVirtual(8, 8)
+	defer a.Close()
+	defer b.Close()
+
+	var got []Connection
+	mux := NewMux()
+	mux.HandleRect(2, 2, 4, 4, HandlerFunc(func(c Connection, x, y uint8, down bool) {
+		got = append(got, c)
+	}))
+	mux.Handle(a, 2, 3, true)
+	mux.Handle(a, 2, 3, false)
+	mux.Handle(b, 2, 3, true)
+
+	if len(got) != 3 {
+		t.Fatalf("handler called %d times, expected 3", len(got))
+	}
+	if got[0] != got[1] {
+		t.Errorf("the events of the same connection got different areas")
+	}
+	if got[0] == got[2] {
+		t.Errorf("the events of different connections got the same area")
+	}
+	if got[0].Rows() != 4 || got[0].Cols() != 4 {
+		t.Errorf("area size = %dx%d, expected 4x4", got[0].Rows(), got[0].Cols())
+	}
+}
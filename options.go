@@ -4,8 +4,38 @@ import "time"
 
 type Option func(*connection)
 
+// PollInterval makes the connection read from the device in the given interval.
+// By default, the connection reads whenever the device has data, so PollInterval
+// is only needed as a fallback for devices that don't wait for data when reading.
 func PollInterval(interval time.Duration) Option {
 	return func(m *connection) {
 		m.pollInterval = interval
 	}
 }
+
+// ReadTimeout sets how long a read waits for data from the device. A shorter timeout lets
+// StopListening and Close return faster, a longer one causes less work while idle.
+// The default is 100ms.
+func ReadTimeout(timeout time.Duration) Option {
+	return func(m *connection) {
+		m.readTimeout = timeout
+	}
+}
+
+// Latency sets the latency timer of the FTDI chip of USB devices: the chip sends the data it
+// collected at the latest after the given time, even if there is none. A shorter latency lets
+// the key presses through faster, a longer one causes less work while idle.
+// The latency is rounded to milliseconds between 1ms and 255ms, the default is 1ms.
+func Latency(latency time.Duration) Option {
+	return func(m *connection) {
+		m.latency = latency
+	}
+}
+
+// HandshakeDelay sets how long the connection waits for the answer of the device,
+// when identifying the kind of the device. The default is one second.
+func HandshakeDelay(delay time.Duration) Option {
+	return func(m *connection) {
+		m.handshakeDelay = delay
+	}
+}
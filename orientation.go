@@ -0,0 +1,152 @@
+package monome
+
+// Orienter is implemented by connections that can change their orientation at runtime.
+// The orientation is applied to the LED output, the key input and to Rows and Cols.
+type Orienter interface {
+	// Rotation returns the clockwise rotation of the grid
+	Rotation() Rotation
+
+	// SetRotation sets the clockwise rotation of the grid
+	SetRotation(Rotation)
+
+	// Mirror returns wether the grid is mirrored horizontally (columns swapped)
+	// and vertically (rows swapped)
+	Mirror() (horizontal, vertical bool)
+
+	// SetMirror sets the horizontal (columns swapped) and vertical (rows swapped) mirroring.
+	// Mirroring is applied after the rotation.
+	SetMirror(horizontal, vertical bool)
+}
+
+var _ Orienter = &connection{}
+
+type orientation struct {
+	rotation Rotation
+	mirrorH  bool
+	mirrorV  bool
+}
+
+// size returns the rows and cols of a device with the given rows and cols after applying the orientation
+func (o orientation) size(rows, cols uint8) (uint8, uint8) {
+	return o.rotation.size(rows, cols)
+}
+
+// toDevice maps x,y to the position on a device with the given rows and cols
+func (o orientation) toDevice(x, y, rows, cols uint8) (uint8, uint8) {
+	r, c := o.size(rows, cols)
+	if o.mirrorV {
+		x = r - 1 - x
+	}
+	if o.mirrorH {
+		y = c - 1 - y
+	}
+	return o.rotation.toPhysical(x, y, rows, cols)
+}
+
+// fromDevice maps x,y of a device with the given rows and cols to the oriented position
+func (o orientation) fromDevice(x, y, rows, cols uint8) (uint8, uint8) {
+	r, c := o.size(rows, cols)
+	x, y = o.rotation.fromPhysical(x, y, rows, cols)
+	if o.mirrorV {
+		x = r - 1 - x
+	}
+	if o.mirrorH {
+		y = c - 1 - y
+	}
+	return x, y
+}
+
+// Rotate sets the clockwise rotation of the device
+func Rotate(r Rotation) Option {
+	return func(m *connection) {
+		m.orientation.rotation = r % 4
+	}
+}
+
+// Mirror sets the horizontal (columns swapped) and vertical (rows swapped) mirroring of the device.
+// Mirroring is applied after the rotation.
+func Mirror(horizontal, vertical bool) Option {
+	return func(m *connection) {
+		m.orientation.mirrorH = horizontal
+		m.orientation.mirrorV = vertical
+	}
+}
+
+func (m *connection) getOrientation() orientation {
+	m.mx.RLock()
+	o := m.orientation
+	m.mx.RUnlock()
+	return o
+}
+
+func (m *connection) Rotation() Rotation {
+	return m.getOrientation().rotation
+}
+
+func (m *connection) SetRotation(r Rotation) {
+	m.mx.Lock()
+	m.orientation.rotation = r % 4
+	m.mx.Unlock()
+}
+
+func (m *connection) Mirror() (horizontal, vertical bool) {
+	o := m.getOrientation()
+	return o.mirrorH, o.mirrorV
+}
+
+func (m *connection) SetMirror(horizontal, vertical bool) {
+	m.mx.Lock()
+	m.orientation.mirrorH = horizontal
+	m.orientation.mirrorV = vertical
+	m.mx.Unlock()
+}
+
+// Rows returns the number of rows, after applying the orientation
+func (m *connection) Rows() uint8 {
+	rows, _ := m.getOrientation().size(m.Device.Rows(), m.Device.Cols())
+	return rows
+}
+
+// Cols returns the number of cols, after applying the orientation
+func (m *connection) Cols() uint8 {
+	_, cols := m.getOrientation().size(m.Device.Rows(), m.Device.Cols())
+	return cols
+}
+
+// toDevice maps x,y in the convention of the connection to the position on the device
+func (m *connection) toDevice(x, y uint8) (uint8, uint8) {
+	p := m.convention.Point(x, y)
+	return m.getOrientation().toDevice(p.Row, p.Col, m.Device.Rows(), m.Device.Cols())
+}
+
+// fromDevice maps x,y of the device to the convention of the connection
+func (m *connection) fromDevice(x, y uint8) (uint8, uint8) {
+	row, col := m.getOrientation().fromDevice(x, y, m.Device.Rows(), m.Device.Cols())
+	return m.convention.XY(Point{Row: row, Col: col})
+}
+
+// Set sets the brightness of x,y after applying the convention and orientation
+func (m *connection) Set(x, y, brightness uint8) error {
+	if err := checkRange(m, x, y); err != nil {
+		return err
+	}
+	x, y = m.toDevice(x, y)
+	return m.setLight(x, y, brightness, func() error {
+		return m.Device.Set(x, y, brightness)
+	})
+}
+
+// Switch switches the light at x,y after applying the convention and orientation
+func (m *connection) Switch(x, y uint8, on bool) error {
+	if err := checkRange(m, x, y); err != nil {
+		return err
+	}
+	x, y = m.toDevice(x, y)
+	var brightness uint8
+	if on {
+		brightness = 15
+	}
+	return m.setLight(x, y, brightness, func() error {
+		return m.Device.Switch(x, y, on)
+	})
+}
@@ -0,0 +1,117 @@
+package monome
+
+// Convention defines the meaning of the x and y coordinates that are
+// passed to a Handler and to Set and Switch of a Connection.
+type Convention uint8
+
+const (
+	// RowCol is the default convention of this package: x is the row and y is the column
+	RowCol Convention = iota
+
+	// ColRow is the convention of the monome OSC spec, serialosc and most other monome tools:
+	// x is the column and y is the row
+	ColRow
+)
+
+func (c Convention) String() string {
+	if c == ColRow {
+		return "x=column,y=row"
+	}
+	return "x=row,y=column"
+}
+
+// Point returns the Point for x and y in the convention
+func (c Convention) Point(x, y uint8) Point {
+	if c == ColRow {
+		return Point{Row: y, Col: x}
+	}
+	return Point{Row: x, Col: y}
+}
+
+// XY returns x and y of the given Point in the convention
+func (c Convention) XY(p Point) (x, y uint8) {
+	if c == ColRow {
+		return p.Col, p.Row
+	}
+	return p.Row, p.Col
+}
+
+// Point is the position of a key, independent of any convention
+type Point struct {
+	Row uint8
+	Col uint8
+}
+
+// Add returns the point moved by the row and column of q
+func (p Point) Add(q Point) Point {
+	return Point{Row: p.Row + q.Row, Col: p.Col + q.Col}
+}
+
+// Sub returns the point moved back by the row and column of q
+func (p Point) Sub(q Point) Point {
+	return Point{Row: p.Row - q.Row, Col: p.Col - q.Col}
+}
+
+// Rect is a rectangle of keys, starting at Min
+type Rect struct {
+	Min  Point
+	Rows uint8
+	Cols uint8
+}
+
+// Contains returns wether p is inside the rectangle
+func (r Rect) Contains(p Point) bool {
+	return p.Row >= r.Min.Row && int(p.Row) < int(r.Min.Row)+int(r.Rows) &&
+		p.Col >= r.Min.Col && int(p.Col) < int(r.Min.Col)+int(r.Cols)
+}
+
+// Bounds returns the rectangle covering all keys of the device
+func Bounds(d Device) Rect {
+	return Rect{Rows: d.Rows(), Cols: d.Cols()}
+}
+
+// conventioner is implemented by devices that have a configurable Convention
+type conventioner interface {
+	Convention() Convention
+}
+
+// ConventionOf returns the Convention of the given device.
+// Devices without a configurable convention use RowCol.
+func ConventionOf(d Device) Convention {
+	if c, ok := d.(conventioner); ok {
+		return c.Convention()
+	}
+	return RowCol
+}
+
+// SetPoint sets the key at p to the given brightness, regardless of the convention of the device
+func SetPoint(d Device, p Point, brightness uint8) error {
+	x, y := ConventionOf(d).XY(p)
+	return d.Set(x, y, brightness)
+}
+
+// SwitchPoint switches the light at p, regardless of the convention of the device
+func SwitchPoint(d Device, p Point, on bool) error {
+	x, y := ConventionOf(d).XY(p)
+	return d.Switch(x, y, on)
+}
+
+// PointHandlerFunc is a function that acts as a Handler and receives
+// the key as a Point, regardless of the convention of the connection
+type PointHandlerFunc func(c Connection, p Point, down bool)
+
+func (h PointHandlerFunc) Handle(c Connection, x, y uint8, down bool) {
+	h(c, ConventionOf(c).Point(x, y), down)
+}
+
+// Coordinates sets the convention for the x and y coordinates of the connection.
+// Use ColRow to get the ordering of the monome OSC spec.
+func Coordinates(c Convention) Option {
+	return func(m *connection) {
+		m.convention = c
+	}
+}
+
+func (m *connection) Convention() Convention {
+	return m.convention
+}
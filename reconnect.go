@@ -0,0 +1,227 @@
+package monome
+
+import (
+	"sync"
+	"time"
+
+	"github.com/karalabe/gousb/usb"
+)
+
+// AutoReconnect keeps the connection alive, when the device is unplugged or the USB
+// connection fails. Instead of closing the connection, it tries to reopen the device with the same
+// serial number every interval. The handler and the events channel stay the same and the lights
+// are restored to their last known state.
+//
+// While reconnecting, Set and Switch only update the known state, that is sent to the device
+// after it has been reopened, and there is a ReconnectingEvent before and a ReconnectedEvent
+// after the reconnection.
+// Devices without a serial number can't be reconnected and are closed as usual.
+func AutoReconnect(interval time.Duration) Option {
+	return func(m *connection) {
+		m.reconnect.interval = interval
+	}
+}
+
+// reconnection holds the state that is needed to reconnect
+type reconnection struct {
+	interval time.Duration
+
+	// mx guards the lights, it is not held while writing to the device
+	mx     sync.Mutex
+	lights map[[2]uint8]uint8
+}
+
+// reconnects returns wether the connection reconnects after failures
+func (m *connection) reconnects() bool {
+	return m.reconnect.interval > 0 && m.serial != ""
+}
+
+// setLight writes the brightness of x,y to the device and remembers it for a reconnection
+func (m *connection) setLight(x, y, brightness uint8, write func() error) error {
+	if !m.reconnects() {
+		return m.write(x, y, write)
+	}
+	m.reconnect.mx.Lock()
+	if m.reconnect.lights == nil {
+		m.reconnect.lights = map[[2]uint8]uint8{}
+	}
+	m.reconnect.lights[[2]uint8{x, y}] = brightness
+	m.reconnect.mx.Unlock()
+
+	if m.isReconnecting() {
+		// the light is restored after reconnecting
+		return nil
+	}
+	// the write might fail and start a reconnection, which dispatches events to handlers
+	// that might set lights, so no lock must be held here
+	return m.write(x, y, write)
+}
+
+// restoreLights sends the known lights to the device after reconnecting. Lights that are set
+// while restoring might be overwritten by the restore, so they are sent again afterwards.
+func (m *connection) restoreLights() error {
+	var errs Errors
+	sent := map[[2]uint8]uint8{}
+	for {
+		m.reconnect.mx.Lock()
+		changed := map[[2]uint8]uint8{}
+		for pos, brightness := range m.reconnect.lights {
+			if b, has := sent[pos]; !has || b != brightness {
+				changed[pos] = brightness
+			}
+		}
+		m.reconnect.mx.Unlock()
+
+		if len(changed) == 0 {
+			break
+		}
+		for pos, brightness := range changed {
+			errs.Add(m.Device.Set(pos[0], pos[1], brightness))
+			sent[pos] = brightness
+		}
+	}
+	if errs.Len() == 0 {
+		return nil
+	}
+	errs.Task = "restore the lights after reconnecting"
+	return &errs
+}
+
+func (m *connection) isReconnecting() bool {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+	return m.reconnecting != nil
+}
+
+// reconnected returns a channel that is closed, when a running reconnection has finished
+// or nil, if the connection is not reconnecting
+func (m *connection) reconnected() chan struct{} {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+	return m.reconnecting
+}
+
+// startReconnect starts reconnecting because of the given error. It returns false,
+// if the connection can't reconnect.
+func (m *connection) startReconnect(cause error) bool {
+	if !m.reconnects() {
+		return false
+	}
+	m.mx.Lock()
+	if m.closed {
+		m.mx.Unlock()
+		return false
+	}
+	if m.reconnecting != nil {
+		m.mx.Unlock()
+		return true
+	}
+	m.reconnecting = make(chan struct{})
+	m.mx.Unlock()
+
+	// wait for running reads and writes, before closing the lost device
+	m.io.Lock()
+	m.mx.Lock()
+	dev, usbCtx := m.dev, m.usbCtx
+	m.dev, m.usbCtx = nil, nil
+	m.mx.Unlock()
+	if dev != nil {
+		dev.Close()
+	}
+	if usbCtx != nil {
+		usbCtx.Close()
+	}
+	m.io.Unlock()
+	m.resetKeys()
+	m.dispatch(m.conn(), ReconnectingEvent{EventHeader: header(m), Err: cause})
+	go m.reconnectLoop()
+	return true
+}
+
+// reconnectLoop tries to reopen the device until it succeeds or the connection is closed
+func (m *connection) reconnectLoop() {
+	ticker := time.NewTicker(m.reconnect.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.Done():
+			m.finishReconnect()
+			return
+		case <-ticker.C:
+		}
+		err := m.reopen()
+		if err == nil {
+			break
+		}
+		m.logger.Debug("could not reconnect to device", "device", m.String(), "error", err)
+	}
+
+	m.finishReconnect()
+	if err := m.restoreLights(); err != nil {
+		m.logger.Error("could not restore the lights", "device", m.String(), "error", err)
+	}
+	m.logger.Info("reconnected to device", "device", m.String())
+	m.dispatch(m.conn(), ReconnectedEvent{EventHeader: header(m)})
+}
+
+// finishReconnect ends the reconnecting state
+func (m *connection) finishReconnect() {
+	m.mx.Lock()
+	if m.reconnecting != nil {
+		close(m.reconnecting)
+		m.reconnecting = nil
+	}
+	m.mx.Unlock()
+}
+
+// reopen looks for the device with the serial number of the connection and opens it
+func (m *connection) reopen() error {
+	usbCtx, err := usb.NewContext()
+	if err != nil {
+		return USBAccessError
+	}
+
+	devs, err := usbCtx.ListDevices(isMonome)
+	if err != nil {
+		usbCtx.Close()
+		return wrapUSBError(err)
+	}
+
+	var dev *usb.Device
+	for _, d := range devs {
+		if dev == nil && usbSerial(d) == m.serial {
+			dev = d
+			continue
+		}
+		d.Close()
+	}
+
+	if dev == nil {
+		usbCtx.Close()
+		return ErrDisconnected
+	}
+
+	// the kind of the device is known, since the serial number is the same
+	if _, err = m.open(dev); err != nil {
+		dev.Close()
+		usbCtx.Close()
+		return err
+	}
+
+	m.mx.Lock()
+	closed := m.closed
+	if closed {
+		m.dev = nil
+	} else {
+		m.usbCtx = usbCtx
+	}
+	m.mx.Unlock()
+
+	if closed {
+		dev.Close()
+		usbCtx.Close()
+		return ConnectionClosedError(m.String())
+	}
+	return nil
+}
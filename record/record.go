@@ -0,0 +1,346 @@
+// Package record records the key events and the lights of monome connections
+// and replays them, e.g. to reproduce bugs that happened during a live show.
+//
+// A session is stored as JSON lines: the first line is a Header with the format and
+// its version, every following line is an Entry. The keys are stored by row and column,
+// so a session can be replayed on connections with a different convention.
+package record
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gomonome/monome"
+)
+
+// Format is the name of the file format
+const Format = "monome-session"
+
+// Version is the version of the file format that is written
+const Version = 1
+
+// ErrFormat is returned, if the data is not a session or the version is not supported
+var ErrFormat = errors.New("not a supported monome session")
+
+// Header is the first line of a session
+type Header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Started time.Time `json:"started"`
+}
+
+// Kind is the kind of an entry
+type Kind string
+
+const (
+	// Key is a key event
+	Key Kind = "key"
+
+	// Light is the setting of a light
+	Light Kind = "light"
+)
+
+// Entry is a recorded key event or light
+type Entry struct {
+	// Time is the time since the start of the recording
+	Time time.Duration `json:"t"`
+
+	Device monome.DeviceID `json:"device"`
+	Kind   Kind            `json:"kind"`
+	Row    uint8           `json:"row"`
+	Col    uint8           `json:"col"`
+
+	// Down is set for key events
+	Down bool `json:"down,omitempty"`
+
+	// Brightness is set for lights
+	Brightness uint8 `json:"brightness,omitempty"`
+}
+
+var (
+	_ monome.Connection = &Recorder{}
+	_ monome.Orienter   = &Recorder{}
+)
+
+// Recorder is a connection that records the key events and lights of the connection it wraps.
+// The handlers that are set or subscribed via the Recorder get the Recorder as their connection,
+// so that the lights they set are recorded.
+type Recorder struct {
+	monome.Connection
+	start       time.Time
+	unsubscribe func()
+	mx          sync.Mutex
+	enc         *json.Encoder
+	err         error
+}
+
+// New starts recording the given connection to w and returns the recording connection.
+// The lights must be set via the Recorder to be recorded, so the handlers should be
+// set via the Recorder too.
+func New(c monome.Connection, w io.Writer) (*Recorder, error) {
+	r := &Recorder{
+		Connection: c,
+		start:      time.Now(),
+		enc:        json.NewEncoder(w),
+	}
+	err := r.enc.Encode(Header{Format: Format, Version: Version, Started: r.start})
+	if err != nil {
+		return nil, err
+	}
+	r.unsubscribe = c.Subscribe(monome.EventHandlerFunc(r.recordKey), monome.OnlyEvents(monome.KeyEvent{}))
+	return r, nil
+}
+
+func (r *Recorder) recordKey(c monome.Connection, ev monome.Event) {
+	ke := ev.(monome.KeyEvent)
+	p := monome.ConventionOf(c).Point(ke.X, ke.Y)
+	r.write(Entry{Time: ke.At.Sub(r.start), Device: ke.Device, Kind: Key, Row: p.Row, Col: p.Col, Down: ke.Down})
+}
+
+func (r *Recorder) recordLight(x, y, brightness uint8) {
+	p := monome.ConventionOf(r.Connection).Point(x, y)
+	r.write(Entry{Time: time.Since(r.start), Device: monome.IDOf(r.Connection), Kind: Light, Row: p.Row, Col: p.Col, Brightness: brightness})
+}
+
+func (r *Recorder) write(e Entry) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.err != nil || r.enc == nil {
+		return
+	}
+	r.err = r.enc.Encode(e)
+}
+
+// through passes the events to the handler with the Recorder as their connection
+type through struct {
+	r *Recorder
+	h monome.EventHandler
+}
+
+func (t through) HandleEvent(_ monome.Connection, ev monome.Event) {
+	t.h.HandleEvent(t.r, ev)
+}
+
+// intercepting is a through for an Interceptor
+type intercepting struct {
+	through
+	i monome.Interceptor
+}
+
+func (t intercepting) Intercept(_ monome.Connection, ev monome.Event) bool {
+	return t.i.Intercept(t.r, ev)
+}
+
+// wrap returns an EventHandler that passes the events to h with r as their connection
+func (r *Recorder) wrap(h monome.EventHandler) monome.EventHandler {
+	t := through{r, h}
+	if i, ok := h.(monome.Interceptor); ok {
+		return intercepting{t, i}
+	}
+	return t
+}
+
+// SetHandler sets the handler for the key events, that gets the Recorder as its connection
+func (r *Recorder) SetHandler(h monome.Handler) {
+	if h == nil {
+		r.Connection.SetHandler(nil)
+		return
+	}
+	r.SetEventHandler(monome.KeyHandler(h))
+}
+
+// SetEventHandler sets the handler for all events, that gets the Recorder as its connection
+func (r *Recorder) SetEventHandler(h monome.EventHandler) {
+	if h == nil {
+		r.Connection.SetEventHandler(nil)
+		return
+	}
+	r.Connection.SetEventHandler(r.wrap(h))
+}
+
+// Subscribe subscribes the handler to the events of the recorded connection.
+// It gets the Recorder as its connection.
+func (r *Recorder) Subscribe(h monome.EventHandler, options ...monome.SubscribeOption) (unsubscribe func()) {
+	return r.Connection.Subscribe(r.wrap(h), options...)
+}
+
+// Rotation returns the rotation of the recorded connection, if it is an Orienter
+func (r *Recorder) Rotation() monome.Rotation {
+	if o, ok := r.Connection.(monome.Orienter); ok {
+		return o.Rotation()
+	}
+	return monome.Rotate0
+}
+
+// SetRotation sets the rotation of the recorded connection, if it is an Orienter
+func (r *Recorder) SetRotation(rot monome.Rotation) {
+	if o, ok := r.Connection.(monome.Orienter); ok {
+		o.SetRotation(rot)
+	}
+}
+
+// Mirror returns the mirroring of the recorded connection, if it is an Orienter
+func (r *Recorder) Mirror() (horizontal, vertical bool) {
+	if o, ok := r.Connection.(monome.Orienter); ok {
+		return o.Mirror()
+	}
+	return false, false
+}
+
+// SetMirror sets the mirroring of the recorded connection, if it is an Orienter
+func (r *Recorder) SetMirror(horizontal, vertical bool) {
+	if o, ok := r.Connection.(monome.Orienter); ok {
+		o.SetMirror(horizontal, vertical)
+	}
+}
+
+// Convention returns the convention of the recorded connection
+func (r *Recorder) Convention() monome.Convention {
+	return monome.ConventionOf(r.Connection)
+}
+
+// Set sets and records the light at x,y
+func (r *Recorder) Set(x, y, brightness uint8) error {
+	err := r.Connection.Set(x, y, brightness)
+	if err == nil {
+		r.recordLight(x, y, brightness)
+	}
+	return err
+}
+
+// Switch switches and records the light at x,y
+func (r *Recorder) Switch(x, y uint8, on bool) error {
+	err := r.Connection.Switch(x, y, on)
+	if err == nil {
+		var brightness uint8
+		if on {
+			brightness = 15
+		}
+		r.recordLight(x, y, brightness)
+	}
+	return err
+}
+
+// Stop stops the recording, without closing the connection.
+// It returns the first error that happened while writing.
+func (r *Recorder) Stop() error {
+	r.unsubscribe()
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.enc = nil
+	return r.err
+}
+
+// Close stops the recording and closes the connection
+func (r *Recorder) Close() error {
+	var errs monome.Errors
+	errs.Add(r.Stop())
+	errs.Add(r.Connection.Close())
+	if errs.Len() == 0 {
+		return nil
+	}
+	errs.Task = "close recording"
+	return &errs
+}
+
+// Reader reads the entries of a session
+type Reader struct {
+	Header
+	dec *json.Decoder
+}
+
+// NewReader reads the header of the session and returns a Reader for its entries
+func NewReader(r io.Reader) (*Reader, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var h Header
+	err := dec.Decode(&h)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	if h.Format != Format || h.Version < 1 || h.Version > Version {
+		return nil, fmt.Errorf("%w: format %q version %d", ErrFormat, h.Format, h.Version)
+	}
+	return &Reader{Header: h, dec: dec}, nil
+}
+
+// Next returns the next entry or io.EOF at the end of the session
+func (r *Reader) Next() (Entry, error) {
+	var e Entry
+	err := r.dec.Decode(&e)
+	return e, err
+}
+
+// Player replays sessions
+type Player struct {
+	// Speed is the factor of the playing speed; 2 plays twice as fast as recorded.
+	// If it is 0, the session is played with the original speed.
+	Speed float64
+
+	// Lights sets the recorded lights on the connection
+	Lights bool
+
+	// Convention is the convention of the keys that are passed to the handler, if there is no connection
+	Convention monome.Convention
+}
+
+// Play passes the recorded key events of the session to the handler h, together with c, at the
+// recorded times, scaled by the speed. The keys are converted to the convention of c.
+// c might be nil, e.g. to replay the keys to an application without a device.
+// It returns nil at the end of the session or the error of ctx, if it is done before.
+func (p Player) Play(ctx context.Context, r *Reader, c monome.Connection, h monome.Handler) error {
+	speed := p.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	conv := p.Convention
+	if c != nil {
+		conv = monome.ConventionOf(c)
+	}
+	start := time.Now()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if wait := time.Until(start.Add(time.Duration(float64(e.Time) / speed))); wait > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		x, y := conv.XY(monome.Point{Row: e.Row, Col: e.Col})
+		switch e.Kind {
+		case Key:
+			h.Handle(c, x, y, e.Down)
+		case Light:
+			if p.Lights && c != nil {
+				c.Set(x, y, e.Brightness)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomonome/monome"
+)
+
+// keys collects the keys passed to the handler as points
+type keys struct {
+	mx     sync.Mutex
+	points []monome.Point
+	downs  []bool
+}
+
+func (k *keys) Handle(c monome.Connection, x, y uint8, down bool) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	k.points = append(k.points, monome.ConventionOf(c).Point(x, y))
+	k.downs = append(k.downs, down)
+}
+
+func TestRecordAndPlay(t *testing.T) {
+	// recorded with RowCol and rotated, played with ColRow
+	v := monome.NewVirtual(8, 16, monome.ReadTimeout(5*time.Millisecond))
+	defer v.Close()
+
+	var session bytes.Buffer
+	rec, err := New(v, &session)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	rec.SetRotation(monome.Rotate180)
+	if v.Rotation() != monome.Rotate180 {
+		t.Fatalf("rotation of the recorded connection = %v, expected %v", v.Rotation(), monome.Rotate180)
+	}
+
+	// the lights set through the connection of the handler are recorded
+	rec.SetHandler(monome.HandlerFunc(func(c monome.Connection, x, y uint8, down bool) {
+		if _, ok := c.(*Recorder); !ok {
+			t.Errorf("handler got %T, expected the Recorder", c)
+		}
+		if down {
+			c.Set(x, y, 15)
+		}
+	}))
+	rec.StartListening(nil)
+	v.Sequence(
+		monome.KeyStep{X: 1, Y: 9, Down: true},
+		monome.KeyStep{X: 1, Y: 9, Wait: 100 * time.Millisecond},
+		monome.KeyStep{X: 6, Y: 2, Down: true, Wait: 100 * time.Millisecond},
+	)
+	if err := v.Settle(time.Second); err != nil {
+		t.Fatalf("Settle() = %v", err)
+	}
+	rec.StopListening()
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+
+	r, err := NewReader(&session)
+	if err != nil {
+		t.Fatalf("NewReader() = %v", err)
+	}
+	target := monome.NewVirtual(8, 16, monome.Coordinates(monome.ColRow), monome.Rotate(monome.Rotate180))
+	defer target.Close()
+
+	var got keys
+	start := time.Now()
+	err = Player{Speed: 4, Lights: true}.Play(context.Background(), r, target, &got)
+	took := time.Since(start)
+	if err != nil {
+		t.Fatalf("Play() = %v", err)
+	}
+	// 200ms recorded, played four times as fast
+	if took < 40*time.Millisecond || took > 150*time.Millisecond {
+		t.Errorf("playing took %v, expected about 50ms", took)
+	}
+
+	expected := []monome.Point{{Row: 1, Col: 9}, {Row: 1, Col: 9}, {Row: 6, Col: 2}}
+	downs := []bool{true, false, true}
+	if len(got.points) != len(expected) {
+		t.Fatalf("played keys = %v, expected %v", got.points, expected)
+	}
+	for i := range expected {
+		if got.points[i] != expected[i] || got.downs[i] != downs[i] {
+			t.Errorf("key %d = %v down: %v, expected %v down: %v", i, got.points[i], got.downs[i], expected[i], downs[i])
+		}
+	}
+	for _, p := range expected {
+		x, y := monome.ColRow.XY(p)
+		if err := target.ExpectLight(x, y, 15); err != nil {
+			t.Error(err)
+		}
+	}
+}
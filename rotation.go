@@ -0,0 +1,70 @@
+package monome
+
+import "fmt"
+
+// Rotation is a clockwise rotation of a grid in steps of 90 degrees
+type Rotation uint8
+
+const (
+	Rotate0 Rotation = iota
+	Rotate90
+	Rotate180
+	Rotate270
+)
+
+// Degrees returns the rotation in degrees
+func (r Rotation) Degrees() int {
+	return int(r%4) * 90
+}
+
+func (r Rotation) String() string {
+	return fmt.Sprintf("%d°", r.Degrees())
+}
+
+// size returns the rows and cols of a grid with the given physical size, after the rotation
+func (r Rotation) size(rows, cols uint8) (uint8, uint8) {
+	if r%2 == 1 {
+		return cols, rows
+	}
+	return rows, cols
+}
+
+// toPhysical maps x,y of the rotated grid to the position on a
+// grid with the given physical rows and cols
+func (r Rotation) toPhysical(x, y, rows, cols uint8) (uint8, uint8) {
+	switch r % 4 {
+	case Rotate90:
+		return rows - 1 - y, x
+	case Rotate180:
+		return rows - 1 - x, cols - 1 - y
+	case Rotate270:
+		return y, cols - 1 - x
+	default:
+		return x, y
+	}
+}
+
+// fromPhysical maps x,y of a grid with the given physical rows and cols
+// to the position on the rotated grid
+func (r Rotation) fromPhysical(x, y, rows, cols uint8) (uint8, uint8) {
+	switch r % 4 {
+	case Rotate90:
+		return y, rows - 1 - x
+	case Rotate180:
+		return rows - 1 - x, cols - 1 - y
+	case Rotate270:
+		return cols - 1 - y, x
+	default:
+		return x, y
+	}
+}
+
+// RotationDegrees returns the Rotation for the given degrees, rounded down to a multiple of 90.
+// Negative degrees rotate counter clockwise.
+func RotationDegrees(degrees int) Rotation {
+	steps := (degrees / 90) % 4
+	if steps < 0 {
+		steps += 4
+	}
+	return Rotation(steps)
+}
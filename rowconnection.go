@@ -1,83 +1,71 @@
 package monome
 
 import (
+	"context"
 	"fmt"
-	"sort"
 )
 
-type sortByCol [][2]int
-
-func (c sortByCol) Len() int {
-	return len(c)
-}
-
-func (c sortByCol) Less(a, b int) bool {
-	return c[a][0] < c[b][0]
-}
-
-func (c sortByCol) Swap(a, b int) {
-	c[a], c[b] = c[b], c[a]
-}
-
 var _ Connection = &rowConnection{}
 
 type rowConnection struct {
-	devices      []Connection
-	colToDev     sortByCol
-	devToCol     map[int]uint8
-	devNameToDev map[string]int
-	name         string
-	cols         uint8
-	rows         uint8
+	dispatcher
+	lifecycle
+	devices []Connection
+	name    string
 }
 
 // RowConnection creates a unified connection out of a row of connections.
 // The order is from left to right.
+// The row connection uses the RowCol convention, independent of the
+// conventions of the devices.
 // The number of columns is the sum of the columns of the devices.
 // The number of rows is the smallest number of rows of any device.
+// The offsets are calculated on each call, so that changes of the
+// orientation of a device are taken into account.
+// The row connection is done and closed as soon as one of the devices is, since
+// it can't be used without it; Close closes all devices.
 func RowConnection(name string, connections ...Connection) Connection {
 	m := &rowConnection{
-		devices:      connections,
-		devToCol:     map[int]uint8{},
-		devNameToDev: map[string]int{},
-		name:         name,
+		dispatcher: newDispatcher(),
+		devices:    connections,
+		name:       name,
 	}
 	if m.name == "" {
 		m.name = "monome row"
 	}
-	m.calcOffsets()
+	for i, dev := range m.devices {
+		idx := i
+		dev.Subscribe(memberHandler(m, func(p Point) (uint8, uint8) {
+			return p.Row, m.startCol(idx) + p.Col
+		}))
+	}
+	watchAll(&m.lifecycle, m.devices)
 	return m
 }
 
-func (m *rowConnection) calcOffsets() {
-	// find out the starting column for the device
-	var startCol int
-	var cols uint8
-	var rows uint8
-
-	for i, dev := range m.devices {
-		m.colToDev = append(m.colToDev, [2]int{startCol, i})
-		m.devToCol[i] = uint8(startCol)
-		m.devNameToDev[dev.String()] = i
-		startCol += int(dev.Cols())
-		cols += dev.Cols()
-		if dev.Rows() < rows || rows == 0 {
-			rows = dev.Rows()
-		}
+// startCol returns the starting column of the device with the given index
+func (m *rowConnection) startCol(idx int) uint8 {
+	var col uint8
+	for _, dev := range m.devices[:idx] {
+		col += dev.Cols()
 	}
-	m.cols = cols
-	m.rows = rows
-	sort.Sort(m.colToDev)
+	return col
 }
 
 // Rows returns the minimum of rows, each device has
 func (m *rowConnection) Rows() uint8 {
-	return m.rows
+	var rows uint8
+	for _, dev := range m.devices {
+		if dev.Rows() < rows || rows == 0 {
+			rows = dev.Rows()
+		}
+	}
+	return rows
 }
 
 // Cols is the sum of the cols of the devices
 func (m *rowConnection) Cols() uint8 {
-	return m.cols
+	return m.startCol(len(m.devices))
 }
 
 func (m *rowConnection) Switch(x, y uint8, on bool) error {
@@ -89,42 +77,37 @@ func (m *rowConnection) Switch(x, y uint8, on bool) error {
 	if err == nil {
 		return nil
 	}
-	e := err.(Error)
 	if on {
-		e.Task = fmt.Sprintf("switch on (%d/%d in row device)", x, y)
-	} else {
-		e.Task = fmt.Sprintf("switch off (%d/%d in row device)", x, y)
+		return withTask(err, fmt.Sprintf("switch on (%d/%d in row device)", x, y))
 	}
-	return e
+	return withTask(err, fmt.Sprintf("switch off (%d/%d in row device)", x, y))
 }
 
 // Set sets the lights to the corresponding device
 func (m *rowConnection) Set(x, y, brightness uint8) error {
+	if err := checkRange(m, x, y); err != nil {
+		return err
+	}
 	var dev int = 0
-	var offset int = 0
-	for _, mp := range m.colToDev {
-		if mp[0] > int(y) {
+	var offset uint8 = 0
+	for i := range m.devices {
+		start := m.startCol(i)
+		if start > y {
 			break
 		}
-		offset += mp[0]
-		dev = mp[1]
+		offset = start
+		dev = i
 	}
-	err := m.devices[dev].Set(x, y-uint8(offset), brightness)
+	err := SetPoint(m.devices[dev], Point{Row: x, Col: y - offset}, brightness)
 	if err == nil {
 		return nil
 	}
 
-	e := err.(Error)
-	e.Task = fmt.Sprintf("set brightness to %d (%d/%d in row device)", brightness, x, y)
-	return e
+	return withTask(err, fmt.Sprintf("set brightness to %d (%d/%d in row device)", brightness, x, y))
 }
 
-func (m *rowConnection) SetHandler(h Handler) {
-	for _, dev := range m.devices {
-		dev.SetHandler(HandlerFunc(func(d Connection, x, y uint8, down bool) {
-			h.Handle(m, x, m.devToCol[m.devNameToDev[d.String()]]+y, down)
-		}))
-	}
+func (m *rowConnection) Events() <-chan Event {
+	return m.eventsOf(m)
 }
 
 func (m *rowConnection) StartListening(errHandler func(error)) {
@@ -133,7 +116,15 @@ func (m *rowConnection) StartListening(errHandler func(error)) {
 	}
 }
 
+// Listen listens to all devices. If one of them fails, the listening to the others is stopped.
+func (m *rowConnection) Listen(ctx context.Context) error {
+	defer m.resetKeys()
+	return listenAll(ctx, m.devices)
+}
+
+// StopListening stops listening to all devices and forgets the held keys
 func (m *rowConnection) StopListening() {
+	defer m.resetKeys()
 	for _, dev := range m.devices {
 		dev.StopListening()
 	}
@@ -189,25 +180,36 @@ func (m *rowDevice) SwitchAll(on bool) error {
 }
 */
 
+// Sync waits for the queued lights of all devices
+func (m *rowConnection) Sync() error {
+	var errs Errors
+	for _, dev := range m.devices {
+		errs.Add(dev.Sync())
+	}
+	if errs.Len() == 0 {
+		return nil
+	}
+	errs.Task = fmt.Sprintf("sync row device %s", m.String())
+	return &errs
+}
+
 // Close closes all devices
 func (m *rowConnection) Close() error {
 	var errs Errors
 	for _, dev := range m.devices {
 		errs.Add(dev.Close())
 	}
+	m.finish(ConnectionClosedError(m.String()))
+	m.closeEvents(m, nil)
 
 	if errs.Len() == 0 {
 		return nil
 	}
+	errs.Task = fmt.Sprintf("close row device %s", m.String())
 	return &errs
 }
 
-// IsClosed only returns true, if all devices are closed
+// IsClosed returns true, as soon as one of the devices is closed, like Done
 func (m *rowConnection) IsClosed() bool {
-	for _, dev := range m.devices {
-		if !dev.IsClosed() {
-			return false
-		}
-	}
-	return true
+	return anyClosed(&m.lifecycle, m.devices)
 }
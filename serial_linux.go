@@ -0,0 +1,94 @@
+//go:build linux && (386 || amd64 || arm || arm64 || riscv64)
+
+package monome
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gomonome/monome/internal/term"
+)
+
+// OpenSerial opens the serial port (or pseudo terminal) at the given path as a Transport,
+// e.g. for a grid that speaks the mext protocol via a serial driver:
+//
+//	t, err := OpenSerial("/dev/ttyUSB0")
+//	...
+//	conn, err := ConnectTransport(t)
+//
+// The port is switched to raw mode with 115200 baud.
+func OpenSerial(path string) (Transport, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := f.SyscallConn()
+	if err == nil {
+		ctrlErr := rc.Control(func(fd uintptr) {
+			_, err = term.MakeRaw(fd, syscall.B115200)
+		})
+		if ctrlErr != nil {
+			err = ctrlErr
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &serial{f: f, readTimeout: defaultReadTimeout}, nil
+}
+
+var _ Transport = &serial{}
+
+// serial is a Transport for a serial port. The serial driver strips the status bytes
+// of the FTDI chip, so they are added to each read.
+type serial struct {
+	f           *os.File
+	mx          sync.Mutex
+	readTimeout time.Duration
+}
+
+func (s *serial) MaxPacketSize() uint16 {
+	return 64
+}
+
+func (s *serial) SetReadTimeout(timeout time.Duration) {
+	s.mx.Lock()
+	s.readTimeout = timeout
+	s.mx.Unlock()
+}
+
+func (s *serial) Read(b []byte) (int, error) {
+	if len(b) <= len(ftdiStatus) {
+		return 0, io.ErrShortBuffer
+	}
+	s.mx.Lock()
+	timeout := s.readTimeout
+	s.mx.Unlock()
+
+	n := copy(b, ftdiStatus[:])
+	if err := s.f.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+	got, err := s.f.Read(b[n:])
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		// no data, like the FTDI chip
+		return n, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n + got, nil
+}
+
+func (s *serial) Write(b []byte) (int, error) {
+	return s.f.Write(b)
+}
+
+func (s *serial) Close() error {
+	return s.f.Close()
+}
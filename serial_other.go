@@ -0,0 +1,14 @@
+//go:build !linux || !(386 || amd64 || arm || arm64 || riscv64)
+
+package monome
+
+import (
+	"errors"
+	"runtime"
+)
+
+// OpenSerial opens the serial port (or pseudo terminal) at the given path as a Transport.
+// It is only supported on linux with 386, amd64, arm, arm64 or riscv64.
+func OpenSerial(path string) (Transport, error) {
+	return nil, errors.New("serial ports are not supported on " + runtime.GOOS + "/" + runtime.GOARCH)
+}
@@ -0,0 +1,289 @@
+package monome
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Splitter splits one Connection into several independent Connections,
+// each one covering a rectangle of the grid. It is the inverse of RowConnection.
+//
+// Each region has its own size, handler and lifecycle. The coordinates of the
+// region start at 0,0 in its top left corner and writes outside of the region
+// return an OutOfRangeError.
+// The underlying connection is listened to as long as at least one region is listening
+// and it is closed when all regions have been closed. A region is done and closed
+// when it is closed itself or when the underlying connection is done.
+//
+// Regions should not overlap: keys that are part of several regions are
+// only passed to the region that was created first.
+type Splitter struct {
+	conn      Connection
+	mux       *Mux
+	mx        sync.Mutex
+	regions   []*region
+	listening int
+}
+
+// Split returns a Splitter for the given connection.
+// The Splitter subscribes to the key events of the connection.
+func Split(c Connection) *Splitter {
+	s := &Splitter{
+		conn: c,
+		mux:  NewMux(),
+	}
+	c.Subscribe(KeyHandler(s.mux), OnlyEvents(KeyEvent{}, ListeningStoppedEvent{}, DeviceRemovedEvent{}))
+	return s
+}
+
+// Region returns a new Connection for the rectangle starting at row x and column y
+// of the underlying connection, spanning the given number of rows and cols.
+func (s *Splitter) Region(name string, x, y, rows, cols uint8) Connection {
+	r := &region{
+		area:       area{Connection: s.conn, x: x, y: y, rows: rows, cols: cols},
+		dispatcher: newDispatcher(),
+		splitter:   s,
+		name:       name,
+	}
+	if r.name == "" {
+		r.name = fmt.Sprintf("%s[%d/%d]", s.conn.String(), x, y)
+	}
+	s.mx.Lock()
+	s.regions = append(s.regions, r)
+	s.mx.Unlock()
+	s.mux.HandleRect(x, y, rows, cols, regionHandler{r})
+	watchAll(&r.lifecycle, []Connection{s.conn})
+	return r
+}
+
+// RegionRect returns a new Connection for the given rectangle of the underlying connection
+func (s *Splitter) RegionRect(name string, r Rect) Connection {
+	return s.Region(name, r.Min.Row, r.Min.Col, r.Rows, r.Cols)
+}
+
+func (s *Splitter) startListening() {
+	s.mx.Lock()
+	s.listening++
+	start := s.listening == 1
+	s.mx.Unlock()
+	if start {
+		s.conn.StartListening(s.handleError)
+	}
+}
+
+func (s *Splitter) stopListening() {
+	s.mx.Lock()
+	s.listening--
+	stop := s.listening == 0
+	s.mx.Unlock()
+	if stop {
+		s.conn.StopListening()
+	}
+}
+
+// handleError passes errors of the underlying connection to the listening regions
+func (s *Splitter) handleError(err error) {
+	s.mx.Lock()
+	regions := make([]*region, len(s.regions))
+	copy(regions, s.regions)
+	s.mx.Unlock()
+
+	for _, r := range regions {
+		r.mx.RLock()
+		errHandler := r.errHandler
+		listening := r.listening
+		r.mx.RUnlock()
+		if listening && errHandler != nil {
+			errHandler(err)
+		}
+	}
+}
+
+func (s *Splitter) close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, r := range s.regions {
+		if !r.isClosed() {
+			return nil
+		}
+	}
+	return s.conn.Close()
+}
+
+var _ Connection = &region{}
+
+type region struct {
+	area
+	dispatcher
+	lifecycle
+	splitter   *Splitter
+	name       string
+	mx         sync.RWMutex
+	errHandler func(error)
+	stop       chan struct{}
+	listening  bool
+	closed     bool
+}
+
+func (r *region) handleKey(_ Connection, x, y uint8, down bool) {
+	r.mx.RLock()
+	listening := r.listening
+	r.mx.RUnlock()
+	if listening {
+		r.handle(r, x, y, down)
+	}
+}
+
+// regionHandler passes the keys of the region from the Mux of the Splitter to the region
+type regionHandler struct {
+	r *region
+}
+
+func (h regionHandler) Handle(c Connection, x, y uint8, down bool) {
+	h.r.handleKey(c, x, y, down)
+}
+
+// Reset forgets the held keys, when the underlying connection stopped listening
+func (h regionHandler) Reset(Connection) {
+	h.r.resetKeys()
+}
+
+func (r *region) String() string {
+	return r.name
+}
+
+// Set sets the brightness of x,y relative to the region.
+func (r *region) Set(x, y, brightness uint8) error {
+	if r.IsClosed() {
+		return ConnectionClosedError(r.String())
+	}
+	return r.area.Set(x, y, brightness)
+}
+
+// Switch switches the light at x,y relative to the region.
+func (r *region) Switch(x, y uint8, on bool) error {
+	if r.IsClosed() {
+		return ConnectionClosedError(r.String())
+	}
+	return r.area.Switch(x, y, on)
+}
+
+func (r *region) IsDown(x, y uint8) bool {
+	return r.dispatcher.IsDown(x, y)
+}
+
+func (r *region) PressedAt(x, y uint8) (time.Time, bool) {
+	return r.dispatcher.PressedAt(x, y)
+}
+
+func (r *region) HeldKeys() []HeldKey {
+	return r.dispatcher.HeldKeys()
+}
+
+func (r *region) SetHandler(h Handler) {
+	r.dispatcher.SetHandler(h)
+}
+
+func (r *region) Events() <-chan Event {
+	return r.eventsOf(r)
+}
+
+func (r *region) StartListening(errHandler func(error)) {
+	r.start(errHandler)
+}
+
+// start starts the listening and returns the channel that is closed by StopListening
+func (r *region) start(errHandler func(error)) (stop chan struct{}, err error) {
+	r.mx.Lock()
+	if r.closed {
+		r.mx.Unlock()
+		return nil, ConnectionClosedError(r.String())
+	}
+	if r.listening {
+		r.mx.Unlock()
+		return nil, ErrListening
+	}
+	r.listening = true
+	r.errHandler = errHandler
+	r.stop = make(chan struct{})
+	stop = r.stop
+	r.mx.Unlock()
+	r.splitter.startListening()
+	return stop, nil
+}
+
+// Listen listens to the region until ctx is done, StopListening is called,
+// the region is closed or the underlying connection fails.
+func (r *region) Listen(ctx context.Context) error {
+	errs := make(chan error, 1)
+	stop, err := r.start(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		r.StopListening()
+		return ctx.Err()
+	case err := <-errs:
+		r.StopListening()
+		return err
+	case <-stop:
+		return nil
+	case <-r.Done():
+		return nil
+	}
+}
+
+func (r *region) StopListening() {
+	r.mx.Lock()
+	if !r.listening {
+		r.mx.Unlock()
+		return
+	}
+	r.listening = false
+	close(r.stop)
+	r.stop = nil
+	r.mx.Unlock()
+	r.resetKeys()
+	r.dispatch(r, ListeningStoppedEvent{EventHeader: header(r)})
+	r.splitter.stopListening()
+}
+
+func (r *region) isClosed() bool {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.closed
+}
+
+// IsClosed returns wether the region or the underlying connection is closed, like Done
+func (r *region) IsClosed() bool {
+	return r.isClosed() || anyClosed(&r.lifecycle, []Connection{r.Connection})
+}
+
+// Close closes the region. The underlying connection is closed
+// when the last of its regions is closed.
+func (r *region) Close() error {
+	r.StopListening()
+	r.mx.Lock()
+	if r.closed {
+		r.mx.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mx.Unlock()
+	r.finish(ConnectionClosedError(r.String()))
+	r.closeEvents(r, nil)
+	return r.splitter.close()
+}
+
+func (r *region) ReadMessage() error {
+	panic("don't call me")
+}
@@ -0,0 +1,112 @@
+package monome
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Interceptor is an EventHandler that may stop the propagation of an event.
+// If a subscribed handler implements Interceptor, Intercept is called instead of HandleEvent.
+// If it returns true, the event is not passed to the following subscriptions and the handler
+// of the connection.
+type Interceptor interface {
+	Intercept(c Connection, ev Event) (stop bool)
+}
+
+// InterceptorFunc is a function that acts as an Interceptor
+type InterceptorFunc func(c Connection, ev Event) (stop bool)
+
+func (i InterceptorFunc) Intercept(c Connection, ev Event) bool {
+	return i(c, ev)
+}
+
+func (i InterceptorFunc) HandleEvent(c Connection, ev Event) {
+	i(c, ev)
+}
+
+// SubscribeOption is an option for a subscription (see Connection.Subscribe)
+type SubscribeOption func(*subscription)
+
+// Filter only passes the events to the subscription, for which f returns true
+func Filter(f func(c Connection, ev Event) bool) SubscribeOption {
+	return func(s *subscription) {
+		s.filters = append(s.filters, f)
+	}
+}
+
+// OnlyEvents only passes the events of the same types as the given examples to the subscription,
+// e.g. OnlyEvents(KeyEvent{}, DeviceRemovedEvent{})
+func OnlyEvents(examples ...Event) SubscribeOption {
+	types := map[reflect.Type]bool{}
+	for _, ex := range examples {
+		types[reflect.TypeOf(ex)] = true
+	}
+	return Filter(func(_ Connection, ev Event) bool {
+		return types[reflect.TypeOf(ev)]
+	})
+}
+
+// InRect only passes the key events inside of r to the subscription. Other events are passed unchanged.
+func InRect(r Rect) SubscribeOption {
+	return Filter(func(c Connection, ev Event) bool {
+		ke, ok := ev.(KeyEvent)
+		if !ok {
+			return true
+		}
+		return r.Contains(ConventionOf(c).Point(ke.X, ke.Y))
+	})
+}
+
+// subscription is a handler that has been subscribed to a connection
+type subscription struct {
+	h       EventHandler
+	filters []func(Connection, Event) bool
+}
+
+func (s *subscription) accepts(c Connection, ev Event) bool {
+	for _, f := range s.filters {
+		if !f(c, ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// deliver passes the event to the subscription and returns true, if the propagation should stop
+func (s *subscription) deliver(c Connection, ev Event) (stop bool) {
+	if i, ok := s.h.(Interceptor); ok {
+		return i.Intercept(c, ev)
+	}
+	s.h.HandleEvent(c, ev)
+	return false
+}
+
+// Subscribe adds a handler for the events. The subscriptions get the events in the order
+// they have been subscribed, before the handler that has been set with SetHandler or SetEventHandler.
+// The returned function removes the subscription.
+func (d *dispatcher) Subscribe(h EventHandler, options ...SubscribeOption) (unsubscribe func()) {
+	s := &subscription{h: h}
+	for _, opt := range options {
+		opt(s)
+	}
+
+	d.dmx.Lock()
+	// copy on write, so that running deliveries keep their list
+	d.subs = append(d.subs[:len(d.subs):len(d.subs)], s)
+	d.dmx.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.dmx.Lock()
+			defer d.dmx.Unlock()
+			subs := make([]*subscription, 0, len(d.subs))
+			for _, other := range d.subs {
+				if other != s {
+					subs = append(subs, other)
+				}
+			}
+			d.subs = subs
+		})
+	}
+}
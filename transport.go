@@ -0,0 +1,95 @@
+package monome
+
+import (
+	"io"
+	"time"
+)
+
+// Transport is the byte stream to a monome device. Connect uses the endpoints of a USB device,
+// ConnectTransport allows other transports, e.g. a serial port (see OpenSerial) or an emulator
+// (see Emulate64 and Emulate128).
+//
+// Read must return the data like the FTDI chip of the devices does: two status bytes, followed
+// by the data. It should wait for data up to the read timeout and then either return the status
+// bytes alone or an error wrapping ErrTimeout.
+type Transport interface {
+	io.ReadWriteCloser
+
+	// MaxPacketSize returns the size of the buffer that is passed to Read
+	MaxPacketSize() uint16
+
+	// SetReadTimeout sets how long Read waits for data
+	SetReadTimeout(time.Duration)
+}
+
+// defaultHandshakeDelay is the time between the query and the reading of the answer
+// when identifying the kind of the device
+var defaultHandshakeDelay = time.Second
+
+// ConnectTransport returns a new Connection to the monome at the other end of the given transport.
+// The kind of the device is identified like by Connect. The transport is closed with the connection.
+// Connections to transports don't reconnect (see AutoReconnect), since they have no serial number.
+func ConnectTransport(t Transport, options ...Option) (Connection, error) {
+	var m = &connection{
+		dev:            t,
+		readTimeout:    defaultReadTimeout,
+		handshakeDelay: defaultHandshakeDelay,
+		logger:         newLogger(nil),
+		dispatcher:     newDispatcher(),
+	}
+
+	for _, opt := range options {
+		opt(m)
+	}
+
+	t.SetReadTimeout(m.readTimeout)
+	device, err := m.identify(t, t, t.MaxPacketSize())
+	if err != nil {
+		return nil, err
+	}
+
+	m.usbReader = t
+	m.usbWriter = t
+	m.maxpacketSizeRead = t.MaxPacketSize()
+	m.Device = device
+	m.startWrites()
+	return m, nil
+}
+
+// identify queries the kind of the monome and returns the matching Device
+func (m *connection) identify(r io.Reader, w io.Writer, maxPacketSize uint16) (Device, error) {
+	var errs Errors
+
+	_, err := w.Write([]byte{0x01, 0x00, 0x00})
+
+	if err != nil {
+		errs.Add(wrapUSBError(err))
+		errs.Task = "initial write to find out the kind of monome"
+		return nil, &errs
+	}
+	time.Sleep(m.handshakeDelay)
+	var b = make([]byte, int(maxPacketSize))
+	got, err := r.Read(b)
+
+	if err != nil {
+		errs.Add(wrapUSBError(err))
+		errs.Task = "initial read to find out the kind of monome"
+		return nil, &errs
+	}
+	b = b[:got]
+
+	//	fmt.Printf("% X (%s) len: %v\n", b[:ln], string(b[:ln]), ln)
+
+	//	monome16x8 = "m1000293" -> 0xF4365 oder 1000293
+	//
+	//	monome8x8  = "m64-0348" -> 0x15C
+
+	switch {
+	case len(b) >= 13 && string(b[3:13]) == "monome 128":
+		return &m128{mn: m}, nil
+	case len(b) > 0 && b[0] == 0x31:
+		return &m64{mn: m}, nil
+	default:
+		return nil, &UnknownMonomeError{Response: b}
+	}
+}
@@ -35,7 +35,7 @@ func PrintUSBDevice(dev *usb.Device) {
 			fmt.Printf("\t\tinterface: %s (%v)\n", iff.String(), iff.Number)
 
 			for _, st := range iff.Setups {
-				fmt.Printf("\t\t\tsetup: %s (%v) IfClass: %d class: %v IfSubclass: %v subclass: %v, protocol: %v, alternate: %v Extra: %v\n",
+				fmt.Printf("\t\t\tsetup: %s (%v) IfClass: %d class: %v IfSubclass: %v subclass: %v, protocol: %v, alternate: %v\n",
 					st.String(),
 					st.Number,
 					st.IfClass,
@@ -48,7 +48,7 @@ func PrintUSBDevice(dev *usb.Device) {
 				)
 
 				for _, ep := range st.Endpoints {
-					fmt.Printf("\t\t\t\tEndpoint: %s (%v) direction: %v, address: %v, attributes: %v, MaxIsoPacket: %v, MaxPacketSize: %v, PollInterval: %v, RefreshRate: %v, SynchAddress: %v, Extra: %v\n",
+					fmt.Printf("\t\t\t\tEndpoint: %s (%v) direction: %v, address: %v, attributes: %v, MaxIsoPacket: %v, MaxPacketSize: %v, PollInterval: %v, RefreshRate: %v, SynchAddress: %v\n",
 						ep.String(),
 						ep.Number(),
 						ep.Direction(),
@@ -81,7 +81,7 @@ func SwitchAll(m Device, on bool) error {
 	cols := m.Cols()
 	for x := uint8(0); x < rows; x++ {
 		for y := uint8(0); y < cols; y++ {
-			errs.Add(m.Switch(x, y, on))
+			errs.Add(SwitchPoint(m, Point{Row: x, Col: y}, on))
 		}
 	}
 	if errs.Len() == 0 {
@@ -97,25 +97,25 @@ func SwitchAll(m Device, on bool) error {
 }
 
 // Greeter prints the name of the device on the device, followed by a flash
-func Greeter(dev Device) {
-	Marquee(dev, dev.String(), time.Millisecond*80)
+func Greeter(dev Device) error {
+	if err := Marquee(dev, dev.String(), time.Millisecond*80); err != nil {
+		return err
+	}
 	time.Sleep(time.Millisecond * 20)
-	SwitchAll(dev, true)
+	if err := SwitchAll(dev, true); err != nil {
+		return err
+	}
 	time.Sleep(time.Millisecond * 300)
-	SwitchAll(dev, false)
+	return SwitchAll(dev, false)
 }
 
 // Marquee shows the given string in a marquee-like manner (from left to right)
 func Marquee(m Device, s string, dur time.Duration) error {
-	var errs Errors
 	s = strings.ToLower(s)
 	s = "   " + s + " "
 	err := SwitchAll(m, false)
 	if err != nil {
-		e := err.(*Errors)
-		e.Task = fmt.Sprintf("blank (switch all off) before marquee on device %s", m.String())
-		errs.Add(e)
-		return &errs
+		return withTask(err, fmt.Sprintf("blank (switch all off) before marquee on device %s", m.String()))
 	}
 
 	// cols is the linear row of all letters, where each letter has some cols
@@ -148,6 +148,9 @@ func Marquee(m Device, s string, dur time.Duration) error {
 		for j := i; j < (i+width) && j < len(cols); j++ {
 
 			for row, on := range cols[j] {
+				if uint8(row) >= m.Rows() {
+					break
+				}
 				//err = m.Switch(uint8(row), targetCol, on)
 				if on {
 					dist := width/2 - int(targetCol)
@@ -155,19 +158,16 @@ func Marquee(m Device, s string, dur time.Duration) error {
 						dist *= (-1)
 					}
 					//err = m.Set(uint8(row), targetCol, uint8(15-dist))
-					err = m.Set(uint8(row), targetCol, uint8(targetCol+1))
+					err = SetPoint(m, Point{Row: uint8(row), Col: targetCol}, uint8(targetCol+1))
 				} else {
-					err = m.Set(uint8(row), targetCol, 0)
+					err = SetPoint(m, Point{Row: uint8(row), Col: targetCol}, 0)
 				}
 				if err != nil {
-					e := err.(Error)
 					what := "off"
 					if on {
 						what = "on"
 					}
-					e.Task = fmt.Sprintf("switch %s %d/%d on device %s while marqueing", what, uint8(row), targetCol, m.String())
-					errs.Add(e)
-					return &errs
+					return withTask(err, fmt.Sprintf("switch %s %d/%d on device %s while marqueing", what, uint8(row), targetCol, m.String()))
 				}
 			}
 			targetCol++
@@ -181,15 +181,11 @@ func Marquee(m Device, s string, dur time.Duration) error {
 
 // Print prints the string one letter after another
 func Print(m Device, s string, dur time.Duration) error {
-	var errs Errors
 	s = strings.ToLower(s)
 	err := SwitchAll(m, false)
 
 	if err != nil {
-		e := err.(*Errors)
-		e.Task = fmt.Sprintf("blank (switch all off) before printing on device %s", m.String())
-		errs.Add(e)
-		return &errs
+		return withTask(err, fmt.Sprintf("blank (switch all off) before printing on device %s", m.String()))
 	}
 
 	for _, l := range s {
@@ -198,23 +194,17 @@ func Print(m Device, s string, dur time.Duration) error {
 			continue
 		}
 		for pt, v := range lt {
-			if v {
-				err = m.Switch(pt[0], pt[1], true)
+			if v && pt[0] < m.Rows() && pt[1] < m.Cols() {
+				err = SwitchPoint(m, Point{Row: pt[0], Col: pt[1]}, true)
 				if err != nil {
-					e := err.(Error)
-					e.Task = fmt.Sprintf("switch on %d/%d on device %s to print letter %q", pt[0], pt[1], m.String(), string(l))
-					errs.Add(e)
-					return &errs
+					return withTask(err, fmt.Sprintf("switch on %d/%d on device %s to print letter %q", pt[0], pt[1], m.String(), string(l)))
 				}
 			}
 		}
 		time.Sleep(dur)
 		err = SwitchAll(m, false)
 		if err != nil {
-			e := err.(*Errors)
-			e.Task = fmt.Sprintf("blank (switch all off) after printing letter %q on device %s", string(l), m.String())
-			errs.Add(e)
-			return &errs
+			return withTask(err, fmt.Sprintf("blank (switch all off) after printing letter %q on device %s", string(l), m.String()))
 		}
 		time.Sleep(dur / 2)
 	}
@@ -265,6 +255,11 @@ func findUSBDevices(vendor_id, product_id string) ([]*usb.Device, error) {
 	return devs, nil
 }
 
+// isMonome returns wether the descriptor is the one of a monome device
+func isMonome(desc *usb.Descriptor) bool {
+	return usb.Class(desc.Class) == 0 && desc.Vendor.String() == VENDOR_ID && desc.Product.String() == PRODUCT_ID
+}
+
 func find(which string, options ...Option) ([]Connection, error) {
 	ctx, err := usb.NewContext()
 
@@ -274,9 +269,7 @@ func find(which string, options ...Option) ([]Connection, error) {
 
 	//	ctx.Debug(4)
 
-	devs, err2 := ctx.ListDevices(func(desc *usb.Descriptor) bool {
-		return usb.Class(desc.Class) == 0 && desc.Vendor.String() == VENDOR_ID && desc.Product.String() == PRODUCT_ID
-	})
+	devs, err2 := ctx.ListDevices(isMonome)
 
 	if err2 != nil {
 		return nil, USBAccessError
@@ -302,5 +295,6 @@ func find(which string, options ...Option) ([]Connection, error) {
 		return ms, nil
 	}
 
+	errs.Task = "connect to the monome devices"
 	return ms, &errs
 }
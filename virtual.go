@@ -0,0 +1,345 @@
+package monome
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Virtual is an in-memory grid of any size that can be used instead of a real device, e.g. in tests.
+// The keys are pressed by calling Press, Release, Tap or Sequence and the lights can be inspected
+// with Light, Snapshot and the Expect methods.
+//
+// The positions passed to the methods of Virtual follow the convention and orientation of the
+// connection, like the positions passed to Set and to the handler.
+type Virtual struct {
+	*connection
+	dev *virtualDevice
+}
+
+var _ Connection = &Virtual{}
+
+// KeyStep is a step of a key sequence (see Virtual.Sequence)
+type KeyStep struct {
+	X    uint8
+	Y    uint8
+	Down bool
+
+	// Wait is the time between the previous step and this one
+	Wait time.Duration
+}
+
+// NewVirtual returns a new virtual grid with the given number of rows and cols.
+// The options are applied as for a real device.
+func NewVirtual(rows, cols uint8, options ...Option) *Virtual {
+	var m = &connection{
+		readTimeout: defaultReadTimeout,
+		logger:      newLogger(nil),
+		dispatcher:  newDispatcher(),
+	}
+
+	for _, opt := range options {
+		opt(m)
+	}
+
+	d := &virtualDevice{
+		mn:      m,
+		rows:    rows,
+		cols:    cols,
+		lights:  make([]uint8, int(rows)*int(cols)),
+		changed: make(chan struct{}, 1),
+	}
+	d.cond = sync.NewCond(&d.mx)
+	m.dev = d
+	m.Device = d
+	v := &Virtual{connection: m, dev: d}
+	// the handlers get the Virtual, so that it can be compared with the connection they get
+	m.self = v
+	m.startWrites()
+	return v
+}
+
+// Press queues the pressing of the key at x,y. It is passed to the handler while the grid is listening.
+func (v *Virtual) Press(x, y uint8) error {
+	return v.Sequence(KeyStep{X: x, Y: y, Down: true})
+}
+
+// Release queues the releasing of the key at x,y
+func (v *Virtual) Release(x, y uint8) error {
+	return v.Sequence(KeyStep{X: x, Y: y})
+}
+
+// Tap queues the pressing and releasing of the key at x,y
+func (v *Virtual) Tap(x, y uint8) error {
+	return v.Sequence(KeyStep{X: x, Y: y, Down: true}, KeyStep{X: x, Y: y})
+}
+
+// Sequence queues the given steps. Each step is passed to the handler after the wait of the step,
+// starting after the previous step, so that gestures like long presses and double taps can be scripted.
+func (v *Virtual) Sequence(steps ...KeyStep) error {
+	keys := make([]virtualKey, 0, len(steps))
+	for _, s := range steps {
+		if err := checkRange(v, s.X, s.Y); err != nil {
+			return err
+		}
+		x, y := v.toDevice(s.X, s.Y)
+		keys = append(keys, virtualKey{x: x, y: y, down: s.Down, wait: s.Wait})
+	}
+	v.dev.queue(keys)
+	return nil
+}
+
+// Settle waits until the queued keys have been passed to the handler.
+// It returns an error wrapping ErrTimeout, if that takes longer than timeout,
+// e.g. because the grid is not listening.
+func (v *Virtual) Settle(timeout time.Duration) error {
+	d := v.dev
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		d.mx.Lock()
+		d.cond.Broadcast()
+		d.mx.Unlock()
+	})
+	defer timer.Stop()
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for d.pending > 0 {
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%d keys of %s not handled: %w", d.pending, v.String(), ErrTimeout)
+		}
+		d.cond.Wait()
+	}
+	return nil
+}
+
+// Fail makes the grid behave like a device that has been unplugged: the reads,
+// writes and the closing of the device fail with the given error.
+func (v *Virtual) Fail(err error) {
+	d := v.dev
+	d.mx.Lock()
+	d.err = err
+	d.mx.Unlock()
+	d.notify()
+}
+
+// Light returns the brightness of the light at x,y. The queued lights are sent before (see Sync).
+func (v *Virtual) Light(x, y uint8) uint8 {
+	v.Sync()
+	if checkRange(v, x, y) != nil {
+		return 0
+	}
+	x, y = v.toDevice(x, y)
+	return v.dev.light(x, y)
+}
+
+// Snapshot returns the lights as text, with a line for each row. Lights that are off are shown
+// as dots, the others as hex digits of their brightness, e.g.
+//
+//	f..f
+//	.88.
+//
+// The queued lights are sent before (see Sync).
+func (v *Virtual) Snapshot() string {
+	v.Sync()
+	o := v.getOrientation()
+	rows, cols := o.size(v.dev.rows, v.dev.cols)
+
+	var bd strings.Builder
+	for r := uint8(0); r < rows; r++ {
+		for c := uint8(0); c < cols; c++ {
+			b := v.dev.light(o.toDevice(r, c, v.dev.rows, v.dev.cols))
+			if b == 0 {
+				bd.WriteByte('.')
+			} else {
+				fmt.Fprintf(&bd, "%x", b&0x0f)
+			}
+		}
+		bd.WriteByte('\n')
+	}
+	return bd.String()
+}
+
+// ExpectLight returns an error, if the light at x,y does not have the given brightness
+func (v *Virtual) ExpectLight(x, y, brightness uint8) error {
+	if got := v.Light(x, y); got != brightness {
+		return fmt.Errorf("light %d,%d of %s: expected brightness %d, got %d", x, y, v.String(), brightness, got)
+	}
+	return nil
+}
+
+// ExpectSnapshot returns an error, if the Snapshot differs from the expected one.
+// Leading and trailing whitespace of the lines and empty lines are ignored, so that
+// the expected snapshot can be written as an indented raw string.
+func (v *Virtual) ExpectSnapshot(expected string) error {
+	got := v.Snapshot()
+	if normalizeSnapshot(got) == normalizeSnapshot(expected) {
+		return nil
+	}
+	return fmt.Errorf("snapshot of %s differs, expected:\n%s\ngot:\n%s", v.String(), normalizeSnapshot(expected), got)
+}
+
+func normalizeSnapshot(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// virtualKey is a queued key event of a virtual grid
+type virtualKey struct {
+	x, y uint8
+	down bool
+	wait time.Duration
+	at   time.Time
+}
+
+var _ Device = &virtualDevice{}
+
+// virtualDevice is the Device of a Virtual; x is the row and y the column
+type virtualDevice struct {
+	mn         *connection
+	rows, cols uint8
+
+	mx      sync.Mutex
+	cond    *sync.Cond
+	lights  []uint8
+	keys    []virtualKey
+	pending int
+	err     error
+
+	// changed receives, when keys have been queued or the device failed
+	changed chan struct{}
+}
+
+func (d *virtualDevice) String() string {
+	return fmt.Sprintf("virtual%dx%d", d.rows, d.cols)
+}
+
+func (d *virtualDevice) Rows() uint8 { return d.rows }
+func (d *virtualDevice) Cols() uint8 { return d.cols }
+
+func (d *virtualDevice) Switch(x, y uint8, on bool) error {
+	var brightness uint8
+	if on {
+		brightness = 15
+	}
+	err := d.Set(x, y, brightness)
+	if err == nil {
+		return nil
+	}
+
+	if on {
+		return withTask(err, "switch on")
+	}
+	return withTask(err, "switch off")
+}
+
+func (d *virtualDevice) Set(x, y, brightness uint8) error {
+	if err := checkRange(d, x, y); err != nil {
+		return err
+	}
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if d.err != nil {
+		var e Error
+		e.Device = d.String()
+		e.X = x
+		e.Y = y
+		e.WrappedError = d.err
+		e.Task = fmt.Sprintf("set brightness to %d", brightness)
+		return e
+	}
+	d.lights[int(x)*int(d.cols)+int(y)] = brightness
+	return nil
+}
+
+func (d *virtualDevice) light(x, y uint8) uint8 {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return d.lights[int(x)*int(d.cols)+int(y)]
+}
+
+// queue queues the keys, the first one waits relative to the last queued key
+func (d *virtualDevice) queue(keys []virtualKey) {
+	d.mx.Lock()
+	at := time.Now()
+	if n := len(d.keys); n > 0 && d.keys[n-1].at.After(at) {
+		at = d.keys[n-1].at
+	}
+	for _, k := range keys {
+		at = at.Add(k.wait)
+		k.at = at
+		d.keys = append(d.keys, k)
+	}
+	d.pending += len(keys)
+	d.mx.Unlock()
+	d.notify()
+}
+
+func (d *virtualDevice) notify() {
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// ReadMessage passes the next key that is due to the handler. It waits up to the read timeout
+// of the connection for a key and returns ErrTimeout if there is none.
+func (d *virtualDevice) ReadMessage() error {
+	timeout := time.NewTimer(d.mn.readTimeout)
+	defer timeout.Stop()
+
+	for {
+		d.mx.Lock()
+		if d.err != nil {
+			err := d.err
+			d.mx.Unlock()
+			return ReadError{Device: d.String(), WrappedError: err}
+		}
+		var wait time.Duration
+		if len(d.keys) > 0 {
+			k := d.keys[0]
+			if wait = time.Until(k.at); wait <= 0 {
+				d.keys = d.keys[1:]
+				d.mx.Unlock()
+
+				d.mn.Handle(d.mn.conn(), k.x, k.y, k.down)
+
+				d.mx.Lock()
+				d.pending--
+				d.cond.Broadcast()
+				d.mx.Unlock()
+				return nil
+			}
+		}
+		d.mx.Unlock()
+
+		var due <-chan time.Time
+		var next *time.Timer
+		if wait > 0 {
+			next = time.NewTimer(wait)
+			due = next.C
+		}
+
+		select {
+		case <-d.changed:
+		case <-due:
+		case <-timeout.C:
+			return ErrTimeout
+		}
+		if next != nil {
+			next.Stop()
+		}
+	}
+}
+
+func (d *virtualDevice) Close() error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return d.err
+}
@@ -0,0 +1,180 @@
+package monome
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// physical returns the lights of the virtual grid without applying the orientation
+func physical(v *Virtual) string {
+	v.Sync()
+	var bd strings.Builder
+	for r := uint8(0); r < v.dev.rows; r++ {
+		for c := uint8(0); c < v.dev.cols; c++ {
+			if v.dev.light(r, c) == 0 {
+				bd.WriteByte('.')
+			} else {
+				bd.WriteByte('x')
+			}
+		}
+		bd.WriteByte('\n')
+	}
+	return bd.String()
+}
+
+func TestVirtualKeys(t *testing.T) {
+	v := NewVirtual(8, 16, ReadTimeout(5*time.Millisecond))
+	defer v.Close()
+
+	type key struct {
+		x, y uint8
+		down bool
+	}
+	keys := make(chan key, 10)
+	v.SetHandler(HandlerFunc(func(d Connection, x, y uint8, down bool) {
+		keys <- key{x, y, down}
+	}))
+	v.StartListening(nil)
+
+	v.Press(3, 12)
+	v.Release(3, 12)
+	v.Tap(7, 0)
+	if err := v.Settle(time.Second); err != nil {
+		t.Fatalf("Settle() = %v", err)
+	}
+	v.StopListening()
+	close(keys)
+
+	var got []key
+	for k := range keys {
+		got = append(got, k)
+	}
+	expected := []key{{3, 12, true}, {3, 12, false}, {7, 0, true}, {7, 0, false}}
+	if len(got) != len(expected) {
+		t.Fatalf("handled keys = %v, expected %v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("key %d = %v, expected %v", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestVirtualSettleTimeout(t *testing.T) {
+	v := NewVirtual(8, 8)
+	defer v.Close()
+
+	v.Press(1, 1)
+	err := v.Settle(20 * time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Settle() without listening = %v, expected an error wrapping ErrTimeout", err)
+	}
+}
+
+func TestVirtualFail(t *testing.T) {
+	v := NewVirtual(8, 8, ReadTimeout(5*time.Millisecond))
+	removed := make(chan DeviceRemovedEvent, 1)
+	v.SetEventHandler(EventHandlerFunc(func(d Connection, ev Event) {
+		if e, ok := ev.(DeviceRemovedEvent); ok {
+			removed <- e
+		}
+	}))
+	v.StartListening(nil)
+
+	unplugged := errors.New("unplugged")
+	v.Fail(unplugged)
+
+	select {
+	case e := <-removed:
+		if !errors.Is(e.Err, unplugged) {
+			t.Errorf("DeviceRemovedEvent.Err = %v, expected %v", e.Err, unplugged)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no DeviceRemovedEvent after Fail")
+	}
+
+	select {
+	case <-v.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done() not closed after Fail")
+	}
+	if !errors.Is(v.Err(), unplugged) {
+		t.Errorf("Err() = %v, expected %v", v.Err(), unplugged)
+	}
+	if err := v.Set(0, 0, 15); err == nil {
+		t.Errorf("Set() after Fail = nil, expected an error")
+	}
+}
+
+func TestVirtualSnapshotOrientation(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		physical string
+	}{
+		{"rotated", []Option{Rotate(Rotate90)}, "..x\nx..\n"},
+		{"rotated and mirrored", []Option{Rotate(Rotate90), Mirror(true, false)}, "x..\n..x\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := NewVirtual(2, 3, test.options...)
+			defer v.Close()
+
+			if v.Rows() != 3 || v.Cols() != 2 {
+				t.Fatalf("size = %dx%d, expected 3x2", v.Rows(), v.Cols())
+			}
+			v.Set(0, 0, 15)
+			v.Set(2, 1, 8)
+
+			err := v.ExpectSnapshot(`
+				f.
+				..
+				.8
+			`)
+			if err != nil {
+				t.Error(err)
+			}
+			if err := v.ExpectLight(2, 1, 8); err != nil {
+				t.Error(err)
+			}
+			if got := physical(v); got != test.physical {
+				t.Errorf("lights on the device:\n%s\nexpected:\n%s", got, test.physical)
+			}
+		})
+	}
+}
+
+func TestVirtualPassedToHandlers(t *testing.T) {
+	v := NewVirtual(8, 8, ReadTimeout(5*time.Millisecond))
+
+	conns := make(chan Connection, 10)
+	v.SetHandler(HandlerFunc(func(d Connection, x, y uint8, down bool) {
+		conns <- d
+	}))
+	v.Subscribe(EventHandlerFunc(func(d Connection, ev Event) {
+		conns <- d
+	}))
+	v.StartListening(nil)
+	v.Press(1, 2)
+	if err := v.Settle(time.Second); err != nil {
+		t.Fatalf("Settle() = %v", err)
+	}
+	v.Close()
+	close(conns)
+
+	var n int
+	for c := range conns {
+		n++
+		if _, ok := c.(*Virtual); !ok || c != Connection(v) {
+			t.Errorf("handler got %T %p, expected the Virtual %p", c, c, v)
+		}
+	}
+	// the KeyEvent for the handler and the subscription,
+	// the ListeningStoppedEvent and the DeviceRemovedEvent
+	if n != 4 {
+		t.Errorf("%d calls of the handlers, expected 4", n)
+	}
+}
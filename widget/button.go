@@ -0,0 +1,127 @@
+package widget
+
+import (
+	"sync"
+
+	"github.com/gomonome/monome"
+)
+
+var (
+	_ Widget = &Toggle{}
+	_ Widget = &Momentary{}
+)
+
+// Toggle is a single key that switches its state on every press
+type Toggle struct {
+	X uint8
+	Y uint8
+	Levels
+
+	// OnChange is called after the state changed (optional)
+	OnChange func(on bool)
+
+	mx sync.Mutex
+	on bool
+}
+
+// NewToggle returns a Toggle at x,y
+func NewToggle(x, y uint8, onChange func(on bool)) *Toggle {
+	return &Toggle{X: x, Y: y, OnChange: onChange}
+}
+
+// Contains returns wether x,y is the key of the toggle
+func (t *Toggle) Contains(x, y uint8) bool {
+	return x == t.X && y == t.Y
+}
+
+// Value returns the state of the toggle
+func (t *Toggle) Value() bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.on
+}
+
+// SetValue sets the state of the toggle without calling OnChange.
+// The LED is not updated until the next call of Draw.
+func (t *Toggle) SetValue(on bool) {
+	t.mx.Lock()
+	t.on = on
+	t.mx.Unlock()
+}
+
+// Handle flips the state on a key press
+func (t *Toggle) Handle(d monome.Connection, x, y uint8, down bool) {
+	x, y = point(d, x, y)
+	if !down || !t.Contains(x, y) {
+		return
+	}
+	t.mx.Lock()
+	t.on = !t.on
+	on := t.on
+	t.mx.Unlock()
+
+	monome.SetPoint(d, monome.Point{Row: t.X, Col: t.Y}, t.level(on))
+	if t.OnChange != nil {
+		t.OnChange(on)
+	}
+}
+
+// Draw lights the key according to the state
+func (t *Toggle) Draw(d monome.Device) error {
+	return monome.SetPoint(d, monome.Point{Row: t.X, Col: t.Y}, t.level(t.Value()))
+}
+
+// Momentary is a single key that is on as long as it is held down
+type Momentary struct {
+	X uint8
+	Y uint8
+	Levels
+
+	// OnChange is called when the key is pressed or released (optional)
+	OnChange func(down bool)
+
+	mx   sync.Mutex
+	down bool
+}
+
+// NewMomentary returns a Momentary at x,y
+func NewMomentary(x, y uint8, onChange func(down bool)) *Momentary {
+	return &Momentary{X: x, Y: y, OnChange: onChange}
+}
+
+// Contains returns wether x,y is the key of the button
+func (m *Momentary) Contains(x, y uint8) bool {
+	return x == m.X && y == m.Y
+}
+
+// Value returns wether the key is held down
+func (m *Momentary) Value() bool {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.down
+}
+
+// Handle tracks the pressing and releasing of the key
+func (m *Momentary) Handle(d monome.Connection, x, y uint8, down bool) {
+	x, y = point(d, x, y)
+	if !m.Contains(x, y) {
+		return
+	}
+	m.mx.Lock()
+	changed := m.down != down
+	m.down = down
+	m.mx.Unlock()
+
+	if !changed {
+		return
+	}
+	monome.SetPoint(d, monome.Point{Row: m.X, Col: m.Y}, m.level(down))
+	if m.OnChange != nil {
+		m.OnChange(down)
+	}
+}
+
+// Draw lights the key while it is held down
+func (m *Momentary) Draw(d monome.Device) error {
+	return monome.SetPoint(d, monome.Point{Row: m.X, Col: m.Y}, m.level(m.Value()))
+}
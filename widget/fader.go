@@ -0,0 +1,87 @@
+package widget
+
+import (
+	"sync"
+
+	"github.com/gomonome/monome"
+)
+
+var _ Widget = &Fader{}
+
+// Fader is a vertical fader made of the key columns of its area.
+// The value ranges from 0 (bottom row) to Rows-1 (top row);
+// all keys from the bottom up to the value are lit.
+type Fader struct {
+	Area
+	Levels
+
+	// OnChange is called with the new value (optional)
+	OnChange func(value uint8)
+
+	mx    sync.Mutex
+	value uint8
+}
+
+// NewFader returns a Fader for the given area with the value 0
+func NewFader(a Area, onChange func(value uint8)) *Fader {
+	return &Fader{Area: a, OnChange: onChange}
+}
+
+// Max returns the maximal value of the fader (0 for an area without rows)
+func (f *Fader) Max() uint8 {
+	if f.Rows == 0 {
+		return 0
+	}
+	return f.Rows - 1
+}
+
+func (f *Fader) valueAt(x uint8) uint8 {
+	return f.X + f.Rows - 1 - x
+}
+
+// Value returns the current value
+func (f *Fader) Value() uint8 {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.value
+}
+
+// SetValue sets the value without calling OnChange.
+// Values above Max are set to Max.
+func (f *Fader) SetValue(value uint8) {
+	if value > f.Max() {
+		value = f.Max()
+	}
+	f.mx.Lock()
+	f.value = value
+	f.mx.Unlock()
+}
+
+// Handle sets the value to the row of the pressed key
+func (f *Fader) Handle(d monome.Connection, x, y uint8, down bool) {
+	x, y = point(d, x, y)
+	if !down || !f.Contains(x, y) {
+		return
+	}
+	v := f.valueAt(x)
+	f.mx.Lock()
+	changed := f.value != v
+	f.value = v
+	f.mx.Unlock()
+
+	if !changed {
+		return
+	}
+	f.Draw(d)
+	if f.OnChange != nil {
+		f.OnChange(v)
+	}
+}
+
+// Draw lights the keys from the bottom up to the value
+func (f *Fader) Draw(d monome.Device) error {
+	value := f.Value()
+	return drawArea(d, f.Area, func(x, y uint8) uint8 {
+		return f.level(f.valueAt(x) <= value)
+	})
+}
@@ -0,0 +1,84 @@
+package widget
+
+import (
+	"sync"
+
+	"github.com/gomonome/monome"
+)
+
+var _ Widget = &Radio{}
+
+// Radio is a group of keys where exactly one key is selected.
+// The keys are numbered row by row, so an area with a single row
+// is a horizontal group and an area with a single column a vertical one.
+type Radio struct {
+	Area
+	Levels
+
+	// OnChange is called with the index of the newly selected key (optional)
+	OnChange func(selected int)
+
+	mx       sync.Mutex
+	selected int
+}
+
+// NewRadio returns a Radio for the given area with the first key selected
+func NewRadio(a Area, onChange func(selected int)) *Radio {
+	return &Radio{Area: a, OnChange: onChange}
+}
+
+// Len returns the number of keys in the group
+func (r *Radio) Len() int {
+	return int(r.Rows) * int(r.Cols)
+}
+
+func (r *Radio) index(x, y uint8) int {
+	return int(x-r.X)*int(r.Cols) + int(y-r.Y)
+}
+
+// Value returns the index of the selected key
+func (r *Radio) Value() int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.selected
+}
+
+// SetValue selects the key with the given index without calling OnChange.
+// Indices outside of the group are ignored.
+func (r *Radio) SetValue(selected int) {
+	if selected < 0 || selected >= r.Len() {
+		return
+	}
+	r.mx.Lock()
+	r.selected = selected
+	r.mx.Unlock()
+}
+
+// Handle selects the pressed key
+func (r *Radio) Handle(d monome.Connection, x, y uint8, down bool) {
+	x, y = point(d, x, y)
+	if !down || !r.Contains(x, y) {
+		return
+	}
+	idx := r.index(x, y)
+	r.mx.Lock()
+	changed := r.selected != idx
+	r.selected = idx
+	r.mx.Unlock()
+
+	if !changed {
+		return
+	}
+	r.Draw(d)
+	if r.OnChange != nil {
+		r.OnChange(idx)
+	}
+}
+
+// Draw lights the selected key
+func (r *Radio) Draw(d monome.Device) error {
+	selected := r.Value()
+	return drawArea(d, r.Area, func(x, y uint8) uint8 {
+		return r.level(r.index(x, y) == selected)
+	})
+}
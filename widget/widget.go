@@ -0,0 +1,173 @@
+// Package widget provides interactive controls for monome grids.
+//
+// A widget owns a rectangle of keys, processes the key events inside of it,
+// draws its own LED feedback and reports value changes via callbacks.
+// Widgets are combined inside a Container which routes the Handle calls of a
+// monome.Connection to the widget that owns the pressed key.
+//
+// The areas of the widgets are defined by row (X) and column (Y). Key events
+// are translated according to the Convention of the connection, so widgets
+// work with every convention.
+package widget
+
+import (
+	"math"
+	"sync"
+
+	"github.com/gomonome/monome"
+)
+
+// Widget is an interactive control on a rectangle of keys
+type Widget interface {
+	monome.Handler
+
+	// Contains returns wether the key at row x and column y belongs to the widget
+	Contains(x, y uint8) bool
+
+	// Draw draws the current state of the widget to the given device
+	Draw(d monome.Device) error
+}
+
+// Area is a rectangle of keys, starting at row X and column Y
+type Area struct {
+	X    uint8
+	Y    uint8
+	Rows uint8
+	Cols uint8
+}
+
+// Contains returns wether the key at row x and column y is inside the area
+func (a Area) Contains(x, y uint8) bool {
+	// computed in int, since the end of the area might exceed the range of uint8
+	return x >= a.X && int(x) < int(a.X)+int(a.Rows) && y >= a.Y && int(y) < int(a.Y)+int(a.Cols)
+}
+
+// Brightness levels used by the widgets, if no level is given
+const (
+	DefaultOn  uint8 = 15
+	DefaultOff uint8 = 0
+)
+
+// Levels are the brightness levels a widget uses to draw itself.
+// A zero On level is replaced by DefaultOn.
+type Levels struct {
+	On  uint8
+	Off uint8
+}
+
+func (l Levels) on() uint8 {
+	if l.On == 0 {
+		return DefaultOn
+	}
+	return l.On
+}
+
+func (l Levels) level(on bool) uint8 {
+	if on {
+		return l.on()
+	}
+	return l.Off
+}
+
+var _ Widget = &Container{}
+
+// Container routes key events to the widgets it contains.
+// If widgets overlap, the widget that was added first wins.
+type Container struct {
+	mx      sync.RWMutex
+	widgets []Widget
+
+	// Unhandled is called for keys that belong to no widget (optional)
+	Unhandled monome.Handler
+}
+
+// NewContainer returns a Container with the given widgets
+func NewContainer(widgets ...Widget) *Container {
+	return &Container{widgets: widgets}
+}
+
+// Add adds the given widgets to the container
+func (c *Container) Add(widgets ...Widget) {
+	c.mx.Lock()
+	c.widgets = append(c.widgets, widgets...)
+	c.mx.Unlock()
+}
+
+// Remove removes the given widget from the container
+func (c *Container) Remove(w Widget) {
+	c.mx.Lock()
+	for i, ww := range c.widgets {
+		if ww == w {
+			c.widgets = append(c.widgets[:i], c.widgets[i+1:]...)
+			break
+		}
+	}
+	c.mx.Unlock()
+}
+
+// Contains returns wether any widget of the container contains x,y
+func (c *Container) Contains(x, y uint8) bool {
+	return c.widgetAt(x, y) != nil
+}
+
+func (c *Container) widgetAt(x, y uint8) Widget {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	for _, w := range c.widgets {
+		if w.Contains(x, y) {
+			return w
+		}
+	}
+	return nil
+}
+
+// Handle passes the key event to the widget that contains x,y
+func (c *Container) Handle(d monome.Connection, x, y uint8, down bool) {
+	if w := c.widgetAt(point(d, x, y)); w != nil {
+		w.Handle(d, x, y, down)
+		return
+	}
+	if c.Unhandled != nil {
+		c.Unhandled.Handle(d, x, y, down)
+	}
+}
+
+// Draw draws all widgets of the container, in the order they were added
+func (c *Container) Draw(d monome.Device) error {
+	c.mx.RLock()
+	widgets := make([]Widget, len(c.widgets))
+	copy(widgets, c.widgets)
+	c.mx.RUnlock()
+
+	var errs monome.Errors
+	for _, w := range widgets {
+		errs.Add(w.Draw(d))
+	}
+	if errs.Len() == 0 {
+		return nil
+	}
+	errs.Task = "draw widgets"
+	return &errs
+}
+
+// point returns the row and column of the key x,y of the given connection
+func point(d monome.Connection, x, y uint8) (row, col uint8) {
+	p := monome.ConventionOf(d).Point(x, y)
+	return p.Row, p.Col
+}
+
+// drawArea sets every key of the area to the brightness returned by level
+func drawArea(d monome.Device, a Area, level func(x, y uint8) uint8) error {
+	var errs monome.Errors
+	// computed in int, since the end of the area might exceed the range of uint8
+	for x := int(a.X); x < int(a.X)+int(a.Rows) && x <= math.MaxUint8; x++ {
+		for y := int(a.Y); y < int(a.Y)+int(a.Cols) && y <= math.MaxUint8; y++ {
+			errs.Add(monome.SetPoint(d, monome.Point{Row: uint8(x), Col: uint8(y)}, level(uint8(x), uint8(y))))
+		}
+	}
+	if errs.Len() == 0 {
+		return nil
+	}
+	errs.Task = "draw widget"
+	return &errs
+}
@@ -0,0 +1,87 @@
+package widget
+
+import (
+	"sync"
+
+	"github.com/gomonome/monome"
+)
+
+var _ Widget = &XYPad{}
+
+// XYPad is a two dimensional control.
+// The value is the position of the last pressed key, relative to the area.
+// The row and column of the position are drawn with the Guide brightness.
+type XYPad struct {
+	Area
+	Levels
+
+	// Guide is the brightness of the row and column that cross the position
+	Guide uint8
+
+	// OnChange is called with the new position (optional)
+	OnChange func(x, y uint8)
+
+	mx sync.Mutex
+	x  uint8
+	y  uint8
+}
+
+// NewXYPad returns a XYPad for the given area with the position 0,0
+func NewXYPad(a Area, onChange func(x, y uint8)) *XYPad {
+	return &XYPad{Area: a, OnChange: onChange}
+}
+
+// Value returns the current position
+func (p *XYPad) Value() (x, y uint8) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.x, p.y
+}
+
+// SetValue sets the position without calling OnChange.
+// Positions outside of the area are ignored.
+func (p *XYPad) SetValue(x, y uint8) {
+	if x >= p.Rows || y >= p.Cols {
+		return
+	}
+	p.mx.Lock()
+	p.x, p.y = x, y
+	p.mx.Unlock()
+}
+
+// Handle moves the position to the pressed key
+func (p *XYPad) Handle(d monome.Connection, x, y uint8, down bool) {
+	x, y = point(d, x, y)
+	if !down || !p.Contains(x, y) {
+		return
+	}
+	px, py := x-p.X, y-p.Y
+	p.mx.Lock()
+	changed := p.x != px || p.y != py
+	p.x, p.y = px, py
+	p.mx.Unlock()
+
+	if !changed {
+		return
+	}
+	p.Draw(d)
+	if p.OnChange != nil {
+		p.OnChange(px, py)
+	}
+}
+
+// Draw lights the position and the guide lines crossing it
+func (p *XYPad) Draw(d monome.Device) error {
+	px, py := p.Value()
+	return drawArea(d, p.Area, func(x, y uint8) uint8 {
+		rx, ry := x-p.X, y-p.Y
+		switch {
+		case rx == px && ry == py:
+			return p.on()
+		case rx == px || ry == py:
+			return p.Guide
+		default:
+			return p.Off
+		}
+	})
+}
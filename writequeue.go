@@ -0,0 +1,171 @@
+package monome
+
+import (
+	"sync"
+	"time"
+)
+
+// WriteQueue makes the connection send the lights from a background goroutine,
+// so that Set and Switch return without waiting for the device.
+//
+// Up to size lights are queued, further calls of Set and Switch wait until there is room.
+// If a light is set again while it is still queued, only the last brightness is sent.
+// At most rate messages per second are sent to the device (no limit, if rate is 0).
+// Errors of the writes are logged, passed to the handler as ErrorEvent and returned by Sync.
+// An error that ends the connection is passed with the DeviceRemovedEvent instead.
+func WriteQueue(size, rate int) Option {
+	return func(m *connection) {
+		if size < 1 {
+			size = 1
+		}
+		q := &writeQueue{
+			size:    size,
+			pending: map[[2]uint8]func() error{},
+		}
+		if rate > 0 {
+			q.interval = time.Second / time.Duration(rate)
+		}
+		q.cond = sync.NewCond(&q.mx)
+		m.writes = q
+	}
+}
+
+// writeQueue is a bounded queue of writes to the lights, keyed by their position
+type writeQueue struct {
+	size     int
+	interval time.Duration
+
+	mx      sync.Mutex
+	cond    *sync.Cond
+	order   [][2]uint8
+	pending map[[2]uint8]func() error
+	busy    bool
+	closed  bool
+	err     error
+}
+
+// put queues the write for the given position, replacing a queued write for the same position
+func (q *writeQueue) put(pos [2]uint8, write func() error) error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if _, has := q.pending[pos]; has {
+		q.pending[pos] = write
+		return nil
+	}
+	for len(q.order) >= q.size && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return ErrClosed
+	}
+	q.order = append(q.order, pos)
+	q.pending[pos] = write
+	q.cond.Broadcast()
+	return nil
+}
+
+// run sends the queued writes until the queue is closed. Errors are passed to failed.
+func (q *writeQueue) run(failed func(error)) {
+	var next time.Time
+	for {
+		q.mx.Lock()
+		for len(q.order) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mx.Unlock()
+			return
+		}
+		pos := q.order[0]
+		q.order = q.order[1:]
+		write := q.pending[pos]
+		delete(q.pending, pos)
+		q.busy = true
+		q.cond.Broadcast()
+		q.mx.Unlock()
+
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+		}
+		err := write()
+		next = time.Now().Add(q.interval)
+
+		q.mx.Lock()
+		q.busy = false
+		if err != nil {
+			q.err = err
+		}
+		q.cond.Broadcast()
+		q.mx.Unlock()
+
+		if err != nil {
+			failed(err)
+		}
+	}
+}
+
+// sync waits until the queue is empty and returns the last error since the previous call
+func (q *writeQueue) sync() error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	for (len(q.order) > 0 || q.busy) && !q.closed {
+		q.cond.Wait()
+	}
+	err := q.err
+	q.err = nil
+	return err
+}
+
+// close discards the queued writes and stops run
+func (q *writeQueue) close() {
+	q.mx.Lock()
+	q.closed = true
+	q.order = nil
+	q.pending = map[[2]uint8]func() error{}
+	q.cond.Broadcast()
+	q.mx.Unlock()
+}
+
+// startWrites starts the writer goroutine, if the connection has a WriteQueue
+func (m *connection) startWrites() {
+	if m.writes == nil {
+		return
+	}
+	go m.writes.run(func(err error) {
+		if m.IsClosed() || m.isReconnecting() {
+			// the error ended the connection and was passed with the DeviceRemovedEvent
+			// (or ReconnectingEvent), no events must follow
+			return
+		}
+		m.logger.Error("could not write to device", "device", m.String(), "error", err)
+		m.dispatch(m.conn(), ErrorEvent{EventHeader: header(m), Err: err})
+	})
+}
+
+// write writes to the light at the given position of the device, either right away or
+// through the WriteQueue
+func (m *connection) write(x, y uint8, write func() error) error {
+	if m.writes == nil {
+		return write()
+	}
+	err := m.writes.put([2]uint8{x, y}, func() error {
+		if m.isReconnecting() {
+			// the light is restored after reconnecting
+			return nil
+		}
+		return write()
+	})
+	if err != nil {
+		return ConnectionClosedError(m.String())
+	}
+	return nil
+}
+
+// Sync waits until the lights that have been queued (see WriteQueue) have been sent to the device.
+// It returns the last error of these writes since the previous call of Sync.
+func (m *connection) Sync() error {
+	if m.writes == nil {
+		return nil
+	}
+	return m.writes.sync()
+}